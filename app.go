@@ -2,9 +2,11 @@ package freya
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/nenormalka/freya/conns/postgres/migrations"
 	"github.com/nenormalka/freya/types"
 
 	"go.uber.org/zap"
@@ -16,51 +18,115 @@ const (
 
 type (
 	App struct {
-		servers  *types.ServerPool
-		services *types.ServicePool
+		serverList  types.ServerList
+		serviceList types.ServiceList
+		servers     *types.ServerPool
+		services    *types.ServicePool
+		probe       *types.Probe
+		migrator    *migrations.Migrator
 
 		logger *zap.Logger
 	}
 )
 
 func NewApp(
-	servers *types.ServerPool,
-	services *types.ServicePool,
+	serverList types.ServerList,
+	serviceList types.ServiceList,
+	probe *types.Probe,
+	migrator *migrations.Migrator,
 	logger *zap.Logger,
 ) *App {
 	return &App{
-		servers:  servers,
-		services: services,
-		logger:   logger,
+		serverList:  serverList,
+		serviceList: serviceList,
+		probe:       probe,
+		migrator:    migrator,
+		logger:      logger,
 	}
 }
 
 func (c *App) Run(ctx context.Context) error {
+	// пулы собираются до миграций, чтобы ошибки в именах и зависимостях Runnable не стоили лишней работы
+	if err := c.buildPools(); err != nil {
+		return err
+	}
+
+	// сервисы и серверы ещё не запущены, откатывать нечего
+	if err := c.migrator.Startup(ctx); err != nil {
+		return fmt.Errorf("migrations err: %w", err)
+	}
+
 	c.logger.Info("Services start")
 
 	if err := c.services.Start(ctx); err != nil {
-		return fmt.Errorf("services start err: %w", err)
+		return c.rollback(fmt.Errorf("services start err: %w", err))
 	}
 
 	c.logger.Info("Servers start")
 	if err := c.servers.Start(ctx); err != nil {
-		return fmt.Errorf("servers start err: %w", err)
+		return c.rollback(fmt.Errorf("servers start err: %w", err))
 	}
 
 	c.logger.Info("Application is ready 🐣")
+	c.probe.SetState(types.ProbeReady)
 
 	<-ctx.Done()
 
+	c.probe.SetState(types.ProbeStopping)
+
 	sdCtx, sdCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer sdCancel()
 
 	c.logger.Info("Stopping servers...")
-	c.servers.Stop(sdCtx)
+	serversErr := c.servers.Stop(sdCtx)
+	if serversErr != nil {
+		c.logger.Error("Servers stopped with errors", zap.Error(serversErr))
+	}
 
 	c.logger.Info("Stopping services...")
-	c.services.Stop(sdCtx)
+	servicesErr := c.services.Stop(sdCtx)
+	if servicesErr != nil {
+		c.logger.Error("Services stopped with errors", zap.Error(servicesErr))
+	}
+
+	if err := errors.Join(serversErr, servicesErr); err != nil {
+		return fmt.Errorf("shutdown err: %w", err)
+	}
 
 	c.logger.Info("Gracefully stopped, bye bye 👋")
 
 	return nil
 }
+
+func (c *App) buildPools() error {
+	services, err := types.NewServicePool(c.serviceList, c.logger)
+	if err != nil {
+		return fmt.Errorf("services pool err: %w", err)
+	}
+
+	servers, err := types.NewServerPool(c.serverList, c.logger)
+	if err != nil {
+		return fmt.Errorf("servers pool err: %w", err)
+	}
+
+	c.services = services
+	c.servers = servers
+
+	return nil
+}
+
+// rollback останавливает всё, что успело запуститься до ошибки старта, и возвращает
+// ошибку старта вместе с ошибками остановки.
+func (c *App) rollback(err error) error {
+	c.logger.Error("Start failed, rolling back...", zap.Error(err))
+	c.probe.SetState(types.ProbeStopping)
+
+	sdCtx, sdCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer sdCancel()
+
+	return errors.Join(
+		err,
+		c.servers.Stop(sdCtx),
+		c.services.Stop(sdCtx),
+	)
+}
@@ -0,0 +1,42 @@
+package freya
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nenormalka/freya/types"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type dependentRunnable struct {
+	name string
+	deps []string
+}
+
+func TestAppRunInvalidPool(t *testing.T) {
+	// мигратора нет: ошибка пула должна вернуться раньше миграций и запуска
+	app := NewApp(nil, types.ServiceList{
+		&dependentRunnable{name: "a", deps: []string{"b"}},
+		&dependentRunnable{name: "b", deps: []string{"a"}},
+	}, types.NewProbe(), nil, zap.NewNop())
+
+	require.ErrorIs(t, app.Run(context.Background()), types.ErrRunnableCycle)
+}
+
+func (r *dependentRunnable) Name() string {
+	return r.name
+}
+
+func (r *dependentRunnable) DependsOn() []string {
+	return r.deps
+}
+
+func (r *dependentRunnable) Start(context.Context) error {
+	return nil
+}
+
+func (r *dependentRunnable) Stop(context.Context) error {
+	return nil
+}
@@ -25,10 +25,16 @@ type (
 		APM             ElasticAPMConfig `yaml:"apm"`
 		Kafka           KafkaConfig      `yaml:"kafka"`
 		DB              []DB             `yaml:"db"`
+		DBRoutes        []DBRoute        `yaml:"db_routes"`
 		ElasticSearch   ElasticSearch    `yaml:"elastic_search"`
 		Sentry          Sentry           `yaml:"sentry"`
 		CouchbaseConfig CouchbaseConfig  `yaml:"couchbase"`
 		ConsulConfig    ConsulConfig     `yaml:"consul"`
+		Health          HealthConfig     `yaml:"health"`
+		GRPCClients     []GRPCClient     `yaml:"grpc_clients"`
+		HTTPClients     []HTTPClient     `yaml:"http_clients"`
+		Resilience      []Resilience     `yaml:"resilience"`
+		Migrations      MigrationsConfig `yaml:"migrations"`
 
 		ReleaseID string
 		Env       string `envconfig:"ENV" default:"development" required:"true" yaml:"env"`
@@ -40,6 +46,25 @@ type (
 		EnableServerMetrics bool `envconfig:"ENABLE_SERVER_METRICS" default:"true" yaml:"enable_server_metrics"`
 	}
 
+	HealthConfig struct {
+		// CriticalChecks имена проверок соединений через запятую без пробелов, падение которых роняет /health.
+		// Например: 'db.sqlx.master,kafka'. Остальные соединения попадают в ответ как наблюдаемые (observer).
+		CriticalChecks string `envconfig:"HEALTH_CRITICAL_CHECKS" yaml:"critical_checks"`
+		// RefreshInterval включает фоновое обновление проверок, ручки отдают последний результат. 0 - выключено.
+		RefreshInterval time.Duration `envconfig:"HEALTH_REFRESH_INTERVAL" default:"0s" yaml:"refresh_interval"`
+		// StaleAfter возраст результата, после которого он считается протухшим, не меньше двух интервалов обновления
+		StaleAfter time.Duration `envconfig:"HEALTH_STALE_AFTER" default:"1m" yaml:"stale_after"`
+	}
+
+	MigrationsConfig struct {
+		// Auto накатывает миграции при старте, до запуска сервисов
+		Auto bool `envconfig:"MIGRATIONS_AUTO" default:"true" yaml:"auto"`
+		// DryRun при старте только пишет в лог, какие миграции были бы применены
+		DryRun bool `envconfig:"MIGRATIONS_DRY_RUN" default:"false" yaml:"dry_run"`
+		// Table таблица с применёнными версиями, можно указать со схемой
+		Table string `envconfig:"MIGRATIONS_TABLE" default:"schema_migrations" yaml:"table"`
+	}
+
 	Sentry struct {
 		DSN string `envconfig:"SENTRY_DSN" yaml:"sentry_dsn"`
 	}
@@ -57,12 +82,41 @@ type (
 		KeepaliveTime            time.Duration `envconfig:"GRPC_KEEPALIVE_TIME" default:"30s" yaml:"keepalive_time"`
 		KeepaliveTimeout         time.Duration `envconfig:"GRPC_KEEPALIVE_TIMEOUT" default:"10s" yaml:"keepalive_timeout"`
 		RegisterReflectionServer bool          `envconfig:"GRPC_REGISTER_REFLECTION_SERVER" default:"true" yaml:"register_reflection_server"`
+		TLS                      GRPCTLSConfig `yaml:"tls"`
+		// GatewayPrefix префикс, под которым grpc-gateway висит на http сервере
+		GatewayPrefix string `envconfig:"GRPC_GATEWAY_PREFIX" default:"/api" yaml:"gateway_prefix"`
+	}
+
+	GRPCTLSConfig struct {
+		// CertFile и KeyFile включают TLS, если заданы оба
+		CertFile string `envconfig:"GRPC_TLS_CERT_FILE" yaml:"cert_file"`
+		KeyFile  string `envconfig:"GRPC_TLS_KEY_FILE" yaml:"key_file"`
+		// ClientCAFile CA для проверки клиентских сертификатов (mTLS)
+		ClientCAFile string `envconfig:"GRPC_TLS_CLIENT_CA_FILE" yaml:"client_ca_file"`
+		// RequireClientCert отклоняет клиентов без сертификата, подписанного ClientCAFile
+		RequireClientCert bool `envconfig:"GRPC_TLS_REQUIRE_CLIENT_CERT" default:"false" yaml:"require_client_cert"`
+		// ReloadInterval как часто проверять, не поменялись ли файлы на диске
+		ReloadInterval time.Duration `envconfig:"GRPC_TLS_RELOAD_INTERVAL" default:"1m" yaml:"reload_interval"`
 	}
 
 	HTTPServerConfig struct {
 		ListenAddr       string        `envconfig:"HTTP_LISTEN_ADDR" required:"true" default:":8080" yaml:"listen_addr"`
 		KeepaliveTime    time.Duration `envconfig:"HTTP_KEEPALIVE_TIME" default:"30s" yaml:"keepalive_time"`
 		KeepaliveTimeout time.Duration `envconfig:"HTTP_KEEPALIVE_TIMEOUT" default:"10s" yaml:"keepalive_timeout"`
+		// LogRedactHeaders заголовки через запятую, значения которых не попадают в логи запросов
+		LogRedactHeaders string `envconfig:"HTTP_LOG_REDACT_HEADERS" default:"Authorization,Cookie,Set-Cookie" yaml:"log_redact_headers"`
+		// LogRedactKeys ключи json тела через запятую, значения которых вычищаются из логов (при DEBUG_LOG)
+		LogRedactKeys string          `envconfig:"HTTP_LOG_REDACT_KEYS" default:"password,token" yaml:"log_redact_keys"`
+		Admin         HTTPAdminConfig `yaml:"admin"`
+	}
+
+	HTTPAdminConfig struct {
+		// ListenAddr включает отдельный сервер для pprof, метрик и health. Пусто - всё висит на основном сервере
+		ListenAddr string `envconfig:"HTTP_ADMIN_LISTEN_ADDR" yaml:"listen_addr"`
+		// User и Password включают basic auth, Token - проверку заголовка Authorization: Bearer <token>
+		User     string `envconfig:"HTTP_ADMIN_USER" yaml:"user"`
+		Password string `envconfig:"HTTP_ADMIN_PASSWORD" yaml:"password"`
+		Token    string `envconfig:"HTTP_ADMIN_TOKEN" yaml:"token"`
 	}
 
 	ElasticAPMConfig struct {
@@ -87,6 +141,66 @@ type (
 		ConnMaxLifetime    time.Duration `yaml:"conn_max_lifetime"`
 	}
 
+	DBRoute struct {
+		// Name имя маршрута, по нему маршрутизирующий коннект достаётся из conns
+		Name string `yaml:"name"`
+		// Primary имя соединения из db, куда идут транзакции
+		Primary string `yaml:"primary"`
+		// Replicas имена соединений из db, между которыми распределяется CallContext
+		Replicas []string `yaml:"replicas"`
+		// Balancer round_robin|least_busy, по дефолту round_robin
+		Balancer string `yaml:"balancer"`
+		// CheckInterval как часто пингуются реплики, упавшие выводятся из ротации
+		CheckInterval time.Duration `yaml:"check_interval"`
+	}
+
+	GRPCClient struct {
+		Name string `yaml:"name"`
+		// Target адрес сервиса: host:port, dns:///host:port или consul://service_name?tag=tag1&tag=tag2
+		Target string `yaml:"target"`
+		// Timeout дедлайн для вызовов, у которых в контексте нет своего
+		Timeout time.Duration `yaml:"timeout"`
+		// MaxAttempts количество попыток с учётом первой, 0 или 1 - без ретраев
+		MaxAttempts int `yaml:"max_attempts"`
+		// RetryBackoff начальная задержка между попытками
+		RetryBackoff time.Duration `yaml:"retry_backoff"`
+		// RetryCodes коды, на которые делается ретрай, по дефолту UNAVAILABLE
+		RetryCodes       []string      `yaml:"retry_codes"`
+		KeepaliveTime    time.Duration `yaml:"keepalive_time"`
+		KeepaliveTimeout time.Duration `yaml:"keepalive_timeout"`
+	}
+
+	HTTPClient struct {
+		Name string `yaml:"name"`
+		// BaseURL адрес апстрима, относительные пути запросов резолвятся от него
+		BaseURL string `yaml:"base_url"`
+		// Timeout общий таймаут запроса с учётом ретраев и чтения тела
+		Timeout time.Duration `yaml:"timeout"`
+		// MaxAttempts количество попыток с учётом первой, ретраятся только идемпотентные методы
+		MaxAttempts int `yaml:"max_attempts"`
+		// RetryBackoff начальная задержка между попытками
+		RetryBackoff        time.Duration     `yaml:"retry_backoff"`
+		MaxIdleConns        int               `yaml:"max_idle_conns"`
+		MaxIdleConnsPerHost int               `yaml:"max_idle_conns_per_host"`
+		IdleConnTimeout     time.Duration     `yaml:"idle_conn_timeout"`
+		Headers             map[string]string `yaml:"headers"`
+	}
+
+	Resilience struct {
+		// Name имя соединения, как у health проверки: db.sqlx.master, db.pgx.master, couchbase, elastic, consul
+		Name string `yaml:"name"`
+		// MaxConcurrent максимум одновременных вызовов, 0 - без ограничения
+		MaxConcurrent int `yaml:"max_concurrent"`
+		// MaxWait сколько вызов ждёт свободного места, прежде чем получить отказ
+		MaxWait time.Duration `yaml:"max_wait"`
+		// Timeout таймаут одного вызова, 0 - без таймаута
+		Timeout time.Duration `yaml:"timeout"`
+		// FailureThreshold ошибок подряд, после которых breaker размыкается, 0 - breaker выключен
+		FailureThreshold int `yaml:"failure_threshold"`
+		// OpenTimeout сколько breaker разомкнут, прежде чем пропустить пробный вызов
+		OpenTimeout time.Duration `yaml:"open_timeout"`
+	}
+
 	CouchbaseConfig struct {
 		DSN         string `envconfig:"COUCHBASE_DSN" yaml:"dsn"`
 		User        string `envconfig:"COUCHBASE_USER" yaml:"user"`
@@ -109,6 +223,9 @@ type (
 const (
 	yamlPathConfig       = "CONFIG_YAML_FILE"
 	defaultDBDSN         = "DB_DSN"
+	grpcClientTarget     = "GRPC_CLIENT_TARGET_"
+	httpClientURL        = "HTTP_CLIENT_URL_"
+	dbRoute              = "DB_ROUTE_"
 	maxOpenConnectionsDB = 25
 	maxIdleConnectionsDB = 5
 )
@@ -199,6 +316,22 @@ func loadENV(cfg *Config) error {
 
 	cfg.DB = getDBConnsENV()
 
+	if cfg.DBRoutes, err = getDBRoutesENV(); err != nil {
+		return err
+	}
+
+	if cfg.Resilience, err = getResilienceENV(); err != nil {
+		return err
+	}
+
+	if cfg.GRPCClients, err = getGRPCClientsENV(); err != nil {
+		return err
+	}
+
+	if cfg.HTTPClients, err = getHTTPClientsENV(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -216,6 +349,22 @@ func getEnvParamInt(param string, defaultValue int) int {
 	return value
 }
 
+// getEnvParamDuration в отличие от getEnvParamInt не подставляет дефолт вместо кривого значения:
+// опечатка в таймауте не должна молча превращаться в другой таймаут
+func getEnvParamDuration(param string, defaultValue time.Duration) (time.Duration, error) {
+	envParam := os.Getenv(param)
+	if envParam == "" {
+		return defaultValue, nil
+	}
+
+	value, err := time.ParseDuration(envParam)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s err %w", param, err)
+	}
+
+	return value, nil
+}
+
 func getEnvParamStr(param string, defaultValue string) string {
 	envParam := os.Getenv(param)
 	if envParam == "" {
@@ -262,3 +411,148 @@ func getDBConnsENV() []DB {
 
 	return dbConns
 }
+
+// getDBRoutesENV собирает маршруты из переменных вида DB_ROUTE_<NAME>=primary,replica1,replica2,
+// балансировщик и интервал проверки общие для всех маршрутов
+func getDBRoutesENV() ([]DBRoute, error) {
+	var routes []DBRoute
+
+	balancer := getEnvParamStr("DB_ROUTE_BALANCER", "")
+
+	checkInterval, err := getEnvParamDuration("DB_ROUTE_CHECK_INTERVAL", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pair := range os.Environ() {
+		if !strings.HasPrefix(pair, dbRoute) {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "DB_ROUTE_BALANCER" || parts[0] == "DB_ROUTE_CHECK_INTERVAL" {
+			continue
+		}
+
+		names := strings.Split(parts[1], ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+
+		routes = append(routes, DBRoute{
+			Name:          strings.ToLower(strings.TrimPrefix(parts[0], dbRoute)),
+			Primary:       names[0],
+			Replicas:      names[1:],
+			Balancer:      balancer,
+			CheckInterval: checkInterval,
+		})
+	}
+
+	return routes, nil
+}
+
+// getGRPCClientsENV собирает клиентов из переменных вида GRPC_CLIENT_TARGET_<NAME>=target,
+// остальные параметры общие для всех клиентов
+func getGRPCClientsENV() ([]GRPCClient, error) {
+	var clients []GRPCClient
+
+	timeout, err := getEnvParamDuration("GRPC_CLIENT_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts := getEnvParamInt("GRPC_CLIENT_MAX_ATTEMPTS", 0)
+
+	for _, pair := range os.Environ() {
+		if !strings.HasPrefix(pair, grpcClientTarget) {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		clients = append(clients, GRPCClient{
+			Name:        strings.ToLower(strings.TrimPrefix(parts[0], grpcClientTarget)),
+			Target:      parts[1],
+			Timeout:     timeout,
+			MaxAttempts: maxAttempts,
+		})
+	}
+
+	return clients, nil
+}
+
+// getHTTPClientsENV собирает клиентов из переменных вида HTTP_CLIENT_URL_<NAME>=base_url,
+// остальные параметры общие для всех клиентов
+func getHTTPClientsENV() ([]HTTPClient, error) {
+	var clients []HTTPClient
+
+	timeout, err := getEnvParamDuration("HTTP_CLIENT_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	maxAttempts := getEnvParamInt("HTTP_CLIENT_MAX_ATTEMPTS", 0)
+
+	for _, pair := range os.Environ() {
+		if !strings.HasPrefix(pair, httpClientURL) {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		clients = append(clients, HTTPClient{
+			Name:        strings.ToLower(strings.TrimPrefix(parts[0], httpClientURL)),
+			BaseURL:     parts[1],
+			Timeout:     timeout,
+			MaxAttempts: maxAttempts,
+		})
+	}
+
+	return clients, nil
+}
+
+// getResilienceENV включает политику для соединений из RESILIENCE_NAMES, параметры общие для всех
+func getResilienceENV() ([]Resilience, error) {
+	var policies []Resilience
+
+	maxWait, err := getEnvParamDuration("RESILIENCE_MAX_WAIT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := getEnvParamDuration("RESILIENCE_TIMEOUT", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	openTimeout, err := getEnvParamDuration("RESILIENCE_OPEN_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	maxConcurrent := getEnvParamInt("RESILIENCE_MAX_CONCURRENT", 0)
+	failureThreshold := getEnvParamInt("RESILIENCE_FAILURE_THRESHOLD", 0)
+
+	for _, name := range strings.Split(getEnvParamStr("RESILIENCE_NAMES", ""), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+
+		policies = append(policies, Resilience{
+			Name:             name,
+			MaxConcurrent:    maxConcurrent,
+			MaxWait:          maxWait,
+			Timeout:          timeout,
+			FailureThreshold: failureThreshold,
+			OpenTimeout:      openTimeout,
+		})
+	}
+
+	return policies, nil
+}
@@ -35,15 +35,30 @@ type (
 			ctx context.Context,
 			txName string,
 			callFunc func(ctx context.Context, tx M) error,
+			opts ...TxOption,
 		) error
 	}
 
+	// TxOption настройка одного вызова CallTransaction. Коннектор читает её из контекста, который
+	// она возвращает, сами опции задаёт пакет коннектора (например postgres.TxModeOption).
+	// Коннекторы без настроек транзакции опции игнорируют.
+	TxOption func(ctx context.Context) context.Context
+
 	DBConnector[T ConnectDB, M ConnectTx] interface {
 		CallContextConnector[T]
 		CallTransactionConnector[M]
 	}
 )
 
+// ApplyTxOptions возвращает контекст с применёнными опциями транзакции
+func ApplyTxOptions(ctx context.Context, opts ...TxOption) context.Context {
+	for _, opt := range opts {
+		ctx = opt(ctx)
+	}
+
+	return ctx
+}
+
 const (
 	DefaultDBConn = "master"
 	SlaveDBConn   = "slave"
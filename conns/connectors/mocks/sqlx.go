@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/nenormalka/freya/conns/connectors"
+
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/jmoiron/sqlx"
 )
@@ -36,6 +38,7 @@ func (s *SQLXMock) CallTransaction(
 	ctx context.Context,
 	_ string,
 	callFunc func(ctx context.Context, tx *sqlx.Tx) error,
+	_ ...connectors.TxOption,
 ) error {
 	tx, err := s.db.BeginTxx(ctx, nil)
 	if err != nil {
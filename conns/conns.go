@@ -1,20 +1,26 @@
 package conns
 
 import (
+	"context"
 	"errors"
+	"sort"
 
 	"github.com/nenormalka/freya/conns/connectors"
 	"github.com/nenormalka/freya/conns/consul"
 	"github.com/nenormalka/freya/conns/couchbase"
 	"github.com/nenormalka/freya/conns/elastic"
+	"github.com/nenormalka/freya/conns/httpclient"
 	"github.com/nenormalka/freya/conns/kafka"
+	postrgres "github.com/nenormalka/freya/conns/postgres"
 	dbtypes "github.com/nenormalka/freya/conns/postgres/types"
+	"github.com/nenormalka/freya/conns/resilience"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type (
@@ -37,12 +43,28 @@ type (
 		goquConns map[string]connectors.DBConnector[*goqu.Database, *goqu.TxDatabase]
 		// db_name -> обёртка над pgx
 		pgxConns map[string]connectors.DBConnector[dbtypes.PgxConn, dbtypes.PgxTx]
+		// routes маршрутизирующие коннекты primary + реплики из db_routes
+		routes *postrgres.Routes
+		// uow транзакции, общие для нескольких репозиториев через контекст
+		uow *postrgres.UnitOfWork
 		// kafka абстракция над кафкой
 		kafka *kafka.Kafka
 		// couchbase абстракция над коучбейсом
 		couchbase *couchbase.Couchbase
 		// consul абстракция над консулом
 		consul *consul.Consul
+		// client_name -> соединение с grpc сервисом
+		grpcClients map[string]*grpc.ClientConn
+		// client_name -> http клиент апстрима
+		httpClients map[string]*httpclient.Client
+		// policies resilience политики соединений, их состояние видно в health
+		policies *resilience.Policies
+	}
+
+	// HealthCheck проверка живого соединения, имя вида `db.sqlx.master` или `kafka`
+	HealthCheck struct {
+		Name  string
+		Check func(ctx context.Context) error
 	}
 )
 
@@ -64,9 +86,14 @@ func NewConns(
 	sqlConns map[string]connectors.DBConnector[*sqlx.DB, *sqlx.Tx],
 	goquConns map[string]connectors.DBConnector[*goqu.Database, *goqu.TxDatabase],
 	pgxConns map[string]connectors.DBConnector[dbtypes.PgxConn, dbtypes.PgxTx],
+	routes *postrgres.Routes,
+	uow *postrgres.UnitOfWork,
 	kafka *kafka.Kafka,
 	couchbase *couchbase.Couchbase,
 	consul *consul.Consul,
+	grpcClients map[string]*grpc.ClientConn,
+	httpClients map[string]*httpclient.Client,
+	policies *resilience.Policies,
 ) *Conns {
 	return &Conns{
 		logger:      logger,
@@ -77,9 +104,14 @@ func NewConns(
 		goquConns:   goquConns,
 		pgxConns:    pgxConns,
 		pgxPoolDB:   pgxPoolDB,
+		routes:      routes,
+		uow:         uow,
 		kafka:       kafka,
 		couchbase:   couchbase,
 		consul:      consul,
+		grpcClients: grpcClients,
+		httpClients: httpClients,
+		policies:    policies,
 	}
 }
 
@@ -108,6 +140,32 @@ func (c *Conns) GetGoQuConn(nameConn string) (connectors.DBConnector[*goqu.Datab
 	return getConn[connectors.DBConnector[*goqu.Database, *goqu.TxDatabase]](c.goquConns, nameConn)
 }
 
+// GetRoutedSQLConn возвращает коннект маршрута из db_routes: транзакции идут в primary, остальное в реплики
+func (c *Conns) GetRoutedSQLConn(route string) (connectors.DBConnector[*sqlx.DB, *sqlx.Tx], error) {
+	return getRoutedConn(c.routes.SQL, route)
+}
+
+// GetRoutedGoQuConn то же, что GetRoutedSQLConn, для goqu
+func (c *Conns) GetRoutedGoQuConn(route string) (connectors.DBConnector[*goqu.Database, *goqu.TxDatabase], error) {
+	return getRoutedConn(c.routes.GoQu, route)
+}
+
+// GetRoutedPGXConn то же, что GetRoutedSQLConn, для pgx
+func (c *Conns) GetRoutedPGXConn(route string) (connectors.DBConnector[dbtypes.PgxConn, dbtypes.PgxTx], error) {
+	return getRoutedConn(c.routes.PGX, route)
+}
+
+// UnitOfWork выполняет f в одной транзакции соединения nameConn. CallTransaction репозиториев на этом
+// соединении с контекстом из f идут в неё же через SAVEPOINT, подробнее в postrgres.UnitOfWork
+func (c *Conns) UnitOfWork(
+	ctx context.Context,
+	nameConn, txName string,
+	f func(ctx context.Context) error,
+	opts ...connectors.TxOption,
+) error {
+	return c.uow.Do(ctx, nameConn, txName, f, opts...)
+}
+
 // GetKafka возвращает абстракцию над кафкой
 func (c *Conns) GetKafka() (*kafka.Kafka, error) {
 	if c.kafka == nil {
@@ -153,6 +211,55 @@ func (c *Conns) GetCouchbase() (*couchbase.Couchbase, error) {
 	return c.couchbase, nil
 }
 
+// GetGRPCClient возвращает соединение с grpc сервисом по имени клиента из конфига
+func (c *Conns) GetGRPCClient(name string) (*grpc.ClientConn, error) {
+	return getConn[*grpc.ClientConn](c.grpcClients, name)
+}
+
+// GetHTTPClient возвращает http клиент апстрима по имени из конфига
+func (c *Conns) GetHTTPClient(name string) (*httpclient.Client, error) {
+	return getConn[*httpclient.Client](c.httpClients, name)
+}
+
+// HealthChecks возвращает проверки для всех поднятых соединений, отсортированные по имени
+func (c *Conns) HealthChecks() []HealthCheck {
+	checks := make([]HealthCheck, 0, len(c.sqlxPoolDB)+len(c.pgxPoolDB)+4)
+
+	for name, db := range c.sqlxPoolDB {
+		checks = append(checks, HealthCheck{Name: postrgres.CheckName(postrgres.ConnTypeSQLX, name), Check: db.PingContext})
+	}
+
+	for name, pool := range c.pgxPoolDB {
+		checks = append(checks, HealthCheck{Name: postrgres.CheckName(postrgres.ConnTypePGX, name), Check: pool.Ping})
+	}
+
+	if c.kafka != nil {
+		checks = append(checks, HealthCheck{Name: "kafka", Check: c.kafka.Ping})
+	}
+
+	if c.couchbase != nil {
+		checks = append(checks, HealthCheck{Name: "couchbase", Check: c.couchbase.Ping})
+	}
+
+	if c.consul != nil {
+		checks = append(checks, HealthCheck{Name: "consul", Check: c.consul.Ping})
+	}
+
+	if c.elasticConn != nil {
+		checks = append(checks, HealthCheck{Name: "elastic", Check: c.elasticConn.Ping})
+	}
+
+	for _, policy := range c.policies.List() {
+		checks = append(checks, HealthCheck{Name: "resilience." + policy.Name(), Check: policy.Check})
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Name < checks[j].Name
+	})
+
+	return checks
+}
+
 func (c *Conns) Close() {
 	c.logger.Info("stopping connections")
 
@@ -172,9 +279,34 @@ func (c *Conns) Close() {
 		}
 	}
 
+	if len(c.grpcClients) != 0 {
+		c.logger.Info("stop grpc clients")
+		for i := range c.grpcClients {
+			if err := c.grpcClients[i].Close(); err != nil {
+				c.logger.Error("grpc client stopping err", zap.Error(err))
+			}
+		}
+	}
+
+	for i := range c.httpClients {
+		c.httpClients[i].CloseIdleConnections()
+	}
+
 	// stop other connections
 }
 
+func getRoutedConn[T connectors.ConnectDB, M connectors.ConnectTx](
+	get func(name string) (*postrgres.RoutedConn[T, M], bool),
+	name string,
+) (connectors.DBConnector[T, M], error) {
+	conn, ok := get(name)
+	if !ok {
+		return nil, errEmptyConn
+	}
+
+	return conn, nil
+}
+
 func getConn[T any](m map[string]T, name string) (T, error) {
 	var t T
 
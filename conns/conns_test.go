@@ -0,0 +1,28 @@
+package conns
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecksNames(t *testing.T) {
+	// sqlx и pgx соединения с одним именем не перетирают проверки друг друга
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+
+	c := &Conns{
+		sqlxPoolDB: map[string]*sqlx.DB{"master": sqlx.NewDb(mockDB, "sqlmock")},
+		pgxPoolDB:  map[string]*pgxpool.Pool{"master": {}},
+	}
+
+	names := make([]string, 0, 2)
+	for _, check := range c.HealthChecks() {
+		names = append(names, check.Name)
+	}
+
+	require.Equal(t, []string{"db.pgx.master", "db.sqlx.master"}, names)
+}
@@ -2,6 +2,7 @@ package consul
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/nenormalka/freya/conns/consul/sd"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/nenormalka/freya/conns/consul/lock"
 	"github.com/nenormalka/freya/conns/consul/session"
 	"github.com/nenormalka/freya/conns/consul/watcher"
+	"github.com/nenormalka/freya/conns/resilience"
 )
 
 type (
@@ -23,7 +25,8 @@ type (
 		cfg config.Config
 		log *zap.Logger
 
-		cli *api.Client
+		cli    *api.Client
+		policy *resilience.Policy
 	}
 
 	Watcher interface {
@@ -61,7 +64,7 @@ type (
 	}
 )
 
-func NewConsul(cfg config.Config, logger *zap.Logger) (*Consul, error) {
+func NewConsul(cfg config.Config, logger *zap.Logger, policies *resilience.Policies) (*Consul, error) {
 	if cfg.Address == "" {
 		return nil, nil
 	}
@@ -72,12 +75,35 @@ func NewConsul(cfg config.Config, logger *zap.Logger) (*Consul, error) {
 	}
 
 	return &Consul{
-		cfg: cfg,
-		log: logger,
-		cli: cli,
+		cfg:    cfg,
+		log:    logger,
+		cli:    cli,
+		policy: policies.Get(policyName),
 	}, nil
 }
 
+const (
+	policyName = "consul"
+)
+
+var (
+	errNoLeader = errors.New("consul cluster has no leader")
+)
+
+// Ping проверяет, что у кластера консула есть лидер
+func (c *Consul) Ping(ctx context.Context) error {
+	leader, err := c.cli.Status().LeaderWithQueryOptions((&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to get consul leader: %w", err)
+	}
+
+	if leader == "" {
+		return errNoLeader
+	}
+
+	return nil
+}
+
 func (c *Consul) Watcher() Watcher {
 	return watcher.NewWatcher(c.cli, c.log)
 }
@@ -87,7 +113,7 @@ func (c *Consul) Locker() Locker {
 }
 
 func (c *Consul) KV() connectors.DBConnector[*api.KV, *api.Txn] {
-	return kv.NewKV(c.cli)
+	return resilience.Wrap[*api.KV, *api.Txn](kv.NewKV(c.cli), c.policy)
 }
 
 func (c *Consul) Session() Session {
@@ -5,6 +5,7 @@ import (
 
 	"github.com/hashicorp/consul/api"
 
+	"github.com/nenormalka/freya/conns/connectors"
 	"github.com/nenormalka/freya/types"
 )
 
@@ -34,6 +35,7 @@ func (kv *KV) CallTransaction(
 	ctx context.Context,
 	txName string,
 	callFunc func(ctx context.Context, tx *api.Txn) error,
+	_ ...connectors.TxOption,
 ) error {
 	return types.WithConsulKVMetrics(txName, func() error {
 		return callFunc(ctx, kv.cli.Txn())
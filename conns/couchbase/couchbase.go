@@ -12,11 +12,13 @@ import (
 	"github.com/nenormalka/freya/conns/connectors"
 	"github.com/nenormalka/freya/conns/couchbase/logger"
 	txtype "github.com/nenormalka/freya/conns/couchbase/types"
+	"github.com/nenormalka/freya/conns/resilience"
 	"github.com/nenormalka/freya/types"
 )
 
 const (
 	defaultTimeout = 5 * time.Second
+	policyName     = "couchbase"
 )
 
 var (
@@ -28,6 +30,7 @@ type (
 		buckets map[string]*gocb.Bucket
 		cluster *gocb.Cluster
 		appName string
+		policy  *resilience.Policy
 	}
 
 	Collection struct {
@@ -40,7 +43,7 @@ type (
 	}
 )
 
-func NewCouchbase(l *zap.Logger, cfg Config) (*Couchbase, error) {
+func NewCouchbase(l *zap.Logger, cfg Config, policies *resilience.Policies) (*Couchbase, error) {
 	if cfg.DSN == "" {
 		return nil, nil
 	}
@@ -86,9 +89,34 @@ func NewCouchbase(l *zap.Logger, cfg Config) (*Couchbase, error) {
 		buckets: buckets,
 		cluster: cluster,
 		appName: cfg.AppName,
+		policy:  policies.Get(policyName),
 	}, nil
 }
 
+// Ping пингует все сервисы кластера и возвращает ошибку, если хоть один эндпоинт не в порядке
+func (c *Couchbase) Ping(ctx context.Context) error {
+	res, err := c.cluster.Ping(&gocb.PingOptions{Context: ctx})
+	if err != nil {
+		return fmt.Errorf("ping couchbase: %w", err)
+	}
+
+	var errs []error
+	for service, endpoints := range res.Services {
+		for _, endpoint := range endpoints {
+			if endpoint.State == gocb.PingStateOk {
+				continue
+			}
+
+			errs = append(errs, fmt.Errorf(
+				"couchbase service %d endpoint %s state %d: %s",
+				service, endpoint.Remote, endpoint.State, endpoint.Error,
+			))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (c *Couchbase) GetCollection(bucketName, collectionName string) (connectors.DBConnector[*gocb.Collection, *txtype.CollectionTx], error) {
 	bucket, ok := c.buckets[bucketName]
 	if !ok {
@@ -107,14 +135,14 @@ func (c *Couchbase) GetCollection(bucketName, collectionName string) (connectors
 		return nil, fmt.Errorf("creating collection in couchbase: %w", err)
 	}
 
-	return &Collection{
+	return resilience.Wrap[*gocb.Collection, *txtype.CollectionTx](&Collection{
 		collection:     bucket.Collection(collectionName),
 		cluster:        c.cluster,
 		bucketName:     bucketName,
 		collectionName: collectionName,
 		appName:        c.appName,
 		bucket:         bucket,
-	}, nil
+	}, c.policy), nil
 }
 
 func (c *Collection) CallContext(
@@ -136,6 +164,7 @@ func (c *Collection) CallTransaction(
 	ctx context.Context,
 	query string,
 	f func(ctx context.Context, c *txtype.CollectionTx) error,
+	_ ...connectors.TxOption,
 ) error {
 	return types.WithCouchbaseMetrics(
 		c.bucketName,
@@ -5,4 +5,5 @@ import "github.com/nenormalka/freya/types"
 var Module = types.Module{
 	{CreateFunc: NewConfig},
 	{CreateFunc: NewCouchbase},
+	{CreateFunc: NewErrorClassifier},
 }
@@ -0,0 +1,36 @@
+package couchbase
+
+import (
+	"errors"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/couchbase/gocb/v2"
+	"go.uber.org/dig"
+)
+
+type (
+	ErrorClassifierOut struct {
+		dig.Out
+
+		Classifier ferrors.Classifier `group:"error_classifiers"`
+	}
+)
+
+func NewErrorClassifier() ErrorClassifierOut {
+	return ErrorClassifierOut{
+		Classifier: ClassifyError,
+	}
+}
+
+// ClassifyError документ не найден -> NotFound, документ уже есть -> AlreadyExists
+func ClassifyError(err error) *ferrors.Error {
+	switch {
+	case errors.Is(err, gocb.ErrDocumentNotFound):
+		return ferrors.NewNotFoundError(err)
+	case errors.Is(err, gocb.ErrDocumentExists):
+		return ferrors.NewAlreadyExistsError(err)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package couchbase
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code ferrors.Code
+	}{
+		{
+			name: "document not found",
+			err:  fmt.Errorf("get order: %w", gocb.ErrDocumentNotFound),
+			code: ferrors.NotFound,
+		},
+		{
+			name: "document exists",
+			err:  gocb.ErrDocumentExists,
+			code: ferrors.AlreadyExists,
+		},
+		{
+			name: "timeout",
+			err:  gocb.ErrTimeout,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ClassifyError(tt.err)
+			if tt.code == 0 {
+				require.Nil(t, e)
+				return
+			}
+
+			require.NotNil(t, e)
+			require.Equal(t, tt.code, e.Code)
+			require.ErrorIs(t, e, tt.err)
+		})
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"github.com/nenormalka/freya/conns/consul"
 	"github.com/nenormalka/freya/conns/couchbase"
 	"github.com/nenormalka/freya/conns/elastic"
+	"github.com/nenormalka/freya/conns/grpcclient"
+	"github.com/nenormalka/freya/conns/httpclient"
 	"github.com/nenormalka/freya/conns/kafka"
 	postrgres "github.com/nenormalka/freya/conns/postgres"
+	"github.com/nenormalka/freya/conns/postgres/migrations"
+	"github.com/nenormalka/freya/conns/resilience"
 	"github.com/nenormalka/freya/types"
 )
 
@@ -13,7 +17,11 @@ var Module = types.Module{
 	{CreateFunc: NewConns},
 }.
 	Append(postrgres.Module).
+	Append(migrations.Module).
 	Append(elastic.Module).
 	Append(kafka.Module).
 	Append(couchbase.Module).
-	Append(consul.Module)
+	Append(consul.Module).
+	Append(grpcclient.Module).
+	Append(httpclient.Module).
+	Append(resilience.Module)
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nenormalka/freya/conns/resilience"
 	"github.com/nenormalka/freya/types"
 
 	estransport "github.com/elastic/elastic-transport-go/v8/elastictransport"
@@ -18,6 +19,7 @@ import (
 )
 
 const (
+	policyName = "elastic"
 	// maxBytesPerBulkRequest 2MB
 	maxBytesPerBulkRequest = 2000000
 	bulkNumWorkers         = 10
@@ -27,6 +29,7 @@ type (
 	ElasticConn struct {
 		client *elasticsearch.Client
 		logger *zap.Logger
+		policy *resilience.Policy
 	}
 
 	BulkIndexerOpts func(cfg *esutil.BulkIndexerConfig)
@@ -104,11 +107,11 @@ func NewElastic(cfg Config) (*elasticsearch.Client, error) {
 
 	res, err := es.Ping()
 	if err != nil {
-		return nil, fmt.Errorf("elasticseach.Ping: %w", err)
+		return nil, fmt.Errorf("elasticsearch.Ping: %w", err)
 	}
 
 	if res.IsError() {
-		return nil, fmt.Errorf("elasticseach.Ping: %s", res.String())
+		return nil, fmt.Errorf("elasticsearch.Ping: %s", res.String())
 	}
 
 	return es, nil
@@ -117,6 +120,7 @@ func NewElastic(cfg Config) (*elasticsearch.Client, error) {
 func NewElasticConn(
 	client *elasticsearch.Client,
 	logger *zap.Logger,
+	policies *resilience.Policies,
 ) *ElasticConn {
 	if client == nil {
 		return nil
@@ -125,6 +129,7 @@ func NewElasticConn(
 	return &ElasticConn{
 		client: client,
 		logger: logger,
+		policy: policies.Get(policyName),
 	}
 }
 
@@ -133,11 +138,28 @@ func (ec *ElasticConn) CallContext(
 	queryName string,
 	callFunc func(ctx context.Context, client *elasticsearch.Client) error,
 ) error {
-	return types.WithElasticMetrics(queryName, func() error {
-		return callFunc(ctx, ec.client)
+	return ec.policy.Do(ctx, func(ctx context.Context) error {
+		return types.WithElasticMetrics(queryName, func() error {
+			return callFunc(ctx, ec.client)
+		})
 	})
 }
 
+func (ec *ElasticConn) Ping(ctx context.Context) error {
+	res, err := ec.client.Ping(ec.client.Ping.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("elasticsearch.Ping: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("elasticsearch.Ping: %s", res.String())
+	}
+
+	return nil
+}
+
 func (ec *ElasticConn) CallContextBulkIndexer(
 	ctx context.Context,
 	queryName string,
@@ -0,0 +1,69 @@
+package grpcclient
+
+import (
+	"time"
+
+	"github.com/nenormalka/freya/config"
+
+	"github.com/nenormalka/bishamon"
+)
+
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultResolveInterval = 10 * time.Second
+)
+
+type (
+	Config struct {
+		Clients  []ClientConfig
+		DebugLog bool
+		// LogRedactor вычищает сенситивные данные из логов, задаётся через WithSensitiveData
+		LogRedactor *bishamon.Redactor
+		// ResolveInterval как часто consul резолвер перечитывает список адресов
+		ResolveInterval time.Duration
+	}
+
+	ClientConfig struct {
+		Name             string
+		Target           string
+		Timeout          time.Duration
+		MaxAttempts      int
+		RetryBackoff     time.Duration
+		RetryCodes       []string
+		KeepaliveTime    time.Duration
+		KeepaliveTimeout time.Duration
+	}
+)
+
+func NewConfig(cfg *config.Config) Config {
+	clients := make([]ClientConfig, 0, len(cfg.GRPCClients))
+
+	for _, c := range cfg.GRPCClients {
+		client := ClientConfig{
+			Name:             c.Name,
+			Target:           c.Target,
+			Timeout:          c.Timeout,
+			MaxAttempts:      c.MaxAttempts,
+			RetryBackoff:     c.RetryBackoff,
+			RetryCodes:       c.RetryCodes,
+			KeepaliveTime:    c.KeepaliveTime,
+			KeepaliveTimeout: c.KeepaliveTimeout,
+		}
+
+		if client.RetryBackoff == 0 {
+			client.RetryBackoff = defaultRetryBackoff
+		}
+
+		if len(client.RetryCodes) == 0 {
+			client.RetryCodes = []string{"UNAVAILABLE"}
+		}
+
+		clients = append(clients, client)
+	}
+
+	return Config{
+		Clients:         clients,
+		DebugLog:        cfg.DebugLog,
+		ResolveInterval: defaultResolveInterval,
+	}
+}
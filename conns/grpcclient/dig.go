@@ -0,0 +1,10 @@
+package grpcclient
+
+import (
+	"github.com/nenormalka/freya/types"
+)
+
+var Module = types.Module{
+	{CreateFunc: NewConfig},
+	{CreateFunc: NewGRPCClients},
+}
@@ -0,0 +1,204 @@
+package grpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nenormalka/freya/conns/consul"
+	"github.com/nenormalka/freya/types"
+
+	"github.com/nenormalka/bishamon"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.elastic.co/apm/module/apmgrpc/v2"
+	"go.uber.org/dig"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/protobuf/runtime/protoimpl"
+)
+
+type (
+	ClientOpt func(*Config)
+
+	Params struct {
+		dig.In
+
+		Config    Config
+		Logger    *zap.Logger
+		Consul    *consul.Consul
+		ClientOpt []ClientOpt `group:"grpc_client_opt"`
+	}
+
+	serviceConfig struct {
+		MethodConfig        []methodConfig   `json:"methodConfig,omitempty"`
+		LoadBalancingConfig []map[string]any `json:"loadBalancingConfig"`
+	}
+
+	methodConfig struct {
+		Name        []map[string]string `json:"name"`
+		RetryPolicy retryPolicy         `json:"retryPolicy"`
+	}
+
+	retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+)
+
+const (
+	retryBackoffMultiplier = 2
+	maxBackoffFactor       = 10
+)
+
+var (
+	registerMetricsOnce sync.Once
+)
+
+// NewGRPCClients создаёт соединения для всех клиентов из конфига. Соединение ленивое,
+// поэтому недоступный сервис не мешает старту.
+func NewGRPCClients(p Params) (map[string]*grpc.ClientConn, error) {
+	if len(p.Config.Clients) == 0 {
+		return nil, nil
+	}
+
+	cfg := p.Config
+	for _, opt := range p.ClientOpt {
+		opt(&cfg)
+	}
+
+	registerMetricsOnce.Do(func() {
+		prometheus.MustRegister(types.ClientGRPCMetrics)
+	})
+
+	var sd consul.ServiceDiscovery
+	if p.Consul != nil {
+		sd = p.Consul.ServiceDiscovery()
+	}
+
+	conns := make(map[string]*grpc.ClientConn, len(cfg.Clients))
+
+	for _, client := range cfg.Clients {
+		opts, err := DialOptions(cfg, client, p.Logger, sd)
+		if err != nil {
+			closeConns(conns, p.Logger)
+			return nil, fmt.Errorf("dial options for grpc client %s: %w", client.Name, err)
+		}
+
+		conn, err := grpc.Dial(client.Target, opts...)
+		if err != nil {
+			closeConns(conns, p.Logger)
+			return nil, fmt.Errorf("dial grpc client %s: %w", client.Name, err)
+		}
+
+		conns[client.Name] = conn
+	}
+
+	return conns, nil
+}
+
+// DialOptions возвращает опции с apm, метриками, логированием, дедлайном по умолчанию, ретраями и keepalive.
+// Пригодится, если соединение нужно создать самостоятельно. sd может быть nil, если consul:// не используется.
+func DialOptions(
+	cfg Config,
+	client ClientConfig,
+	logger *zap.Logger,
+	sd consul.ServiceDiscovery,
+) ([]grpc.DialOption, error) {
+	sc, err := json.Marshal(newServiceConfig(client))
+	if err != nil {
+		return nil, fmt.Errorf("marshal service config: %w", err)
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(string(sc)),
+		grpc.WithChainUnaryInterceptor(
+			ErrorInterceptor(),
+			apmgrpc.NewUnaryClientInterceptor(),
+			types.ClientGRPCMetrics.UnaryClientInterceptor(),
+			timeoutInterceptor(client.Timeout),
+			payloadLoggingInterceptor(logger, cfg, client.Name),
+		),
+		grpc.WithChainStreamInterceptor(
+			ErrorStreamInterceptor(),
+			apmgrpc.NewStreamClientInterceptor(),
+			types.ClientGRPCMetrics.StreamClientInterceptor(),
+			payloadLoggingStreamInterceptor(logger, cfg, client.Name),
+		),
+	}
+
+	if client.KeepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                client.KeepaliveTime,
+			Timeout:             client.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+
+	if strings.HasPrefix(client.Target, consulScheme+"://") {
+		if sd == nil {
+			return nil, errNoConsul
+		}
+
+		// Config может быть собран руками, а time.NewTicker с нулём паникует
+		interval := cfg.ResolveInterval
+		if interval <= 0 {
+			interval = defaultResolveInterval
+		}
+
+		opts = append(opts, grpc.WithResolvers(newConsulBuilder(sd, interval, logger)))
+	}
+
+	return opts, nil
+}
+
+func WithSensitiveData(sensitiveData *protoimpl.ExtensionInfo) (ClientOpt, error) {
+	redactor, err := bishamon.NewClearRedactor(
+		sensitiveData,
+		bishamon.WithFieldsFromMapFunc(bishamon.CommonFieldsFromMapFunc),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create redactor err: %w", err)
+	}
+
+	return func(cfg *Config) {
+		cfg.LogRedactor = redactor
+	}, nil
+}
+
+func newServiceConfig(client ClientConfig) serviceConfig {
+	sc := serviceConfig{
+		LoadBalancingConfig: []map[string]any{{"round_robin": struct{}{}}},
+	}
+
+	if client.MaxAttempts <= 1 {
+		return sc
+	}
+
+	sc.MethodConfig = []methodConfig{{
+		Name: []map[string]string{{}},
+		RetryPolicy: retryPolicy{
+			MaxAttempts:          client.MaxAttempts,
+			InitialBackoff:       fmt.Sprintf("%.3fs", client.RetryBackoff.Seconds()),
+			MaxBackoff:           fmt.Sprintf("%.3fs", (client.RetryBackoff * maxBackoffFactor).Seconds()),
+			BackoffMultiplier:    retryBackoffMultiplier,
+			RetryableStatusCodes: client.RetryCodes,
+		},
+	}}
+
+	return sc
+}
+
+func closeConns(conns map[string]*grpc.ClientConn, logger *zap.Logger) {
+	for name, conn := range conns {
+		if err := conn.Close(); err != nil {
+			logger.Error("close grpc client", zap.String("name", name), zap.Error(err))
+		}
+	}
+}
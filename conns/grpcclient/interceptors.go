@@ -0,0 +1,225 @@
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/nenormalka/freya/logger/redact"
+	"github.com/nenormalka/freya/types/errors"
+
+	grpczap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
+	"go.elastic.co/apm/v2"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// errorClientStream декодирует ошибки стрима в *errors.Error
+	errorClientStream struct {
+		grpc.ClientStream
+	}
+
+	// payloadLoggingClientStream логирует сообщения стрима с DebugLog и его завершение
+	payloadLoggingClientStream struct {
+		grpc.ClientStream
+
+		logger   *zap.Logger
+		cfg      Config
+		method   string
+		fields   []zap.Field
+		finished atomic.Bool
+	}
+)
+
+// ErrorInterceptor превращает grpc статус ответа обратно в *errors.Error, чтобы errors.As и проверки
+// кодов работали через границу сервисов
+func ErrorInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		return errors.FromGRPCError(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+func ErrorStreamInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, errors.FromGRPCError(err)
+		}
+
+		return &errorClientStream{ClientStream: cs}, nil
+	}
+}
+
+func (s *errorClientStream) SendMsg(m any) error {
+	return errors.FromGRPCError(s.ClientStream.SendMsg(m))
+}
+
+func (s *errorClientStream) RecvMsg(m any) error {
+	return errors.FromGRPCError(s.ClientStream.RecvMsg(m))
+}
+
+// timeoutInterceptor ставит дедлайн по умолчанию, если вызывающий не задал свой
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func payloadLoggingInterceptor(logger *zap.Logger, cfg Config, name string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+	) error {
+		apiLogger := logger.Named("grpc_client")
+		fields := []zap.Field{
+			zap.String("grpc.client", name),
+			zap.String("grpc.method", path.Base(method)),
+			fieldWithTraceID(ctx),
+		}
+
+		if cfg.DebugLog {
+			apiLogger.Info(
+				fmt.Sprintf("unary client call %s", method),
+				append(fields, zap.String("grpc.payload", redact.Proto(req, cfg.LogRedactor)))...,
+			)
+		}
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+
+		code := status.Code(err)
+		level := grpczap.DefaultCodeToLevel(code)
+
+		if !cfg.DebugLog && !zap.WarnLevel.Enabled(level) {
+			return err
+		}
+
+		responseField := zap.Skip()
+		if cfg.DebugLog && err == nil {
+			responseField = zap.String("grpc.payload", redact.Proto(reply, cfg.LogRedactor))
+		}
+
+		apiLogger.Log(
+			level,
+			fmt.Sprintf("finished unary client call with code %s", code.String()),
+			append(fields, zap.String("grpc.code", code.String()), responseField, zap.Error(err))...,
+		)
+
+		return err
+	}
+}
+
+func payloadLoggingStreamInterceptor(logger *zap.Logger, cfg Config, name string) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		s := &payloadLoggingClientStream{
+			logger: logger.Named("grpc_client"),
+			cfg:    cfg,
+			method: method,
+			fields: []zap.Field{
+				zap.String("grpc.client", name),
+				zap.String("grpc.method", path.Base(method)),
+				fieldWithTraceID(ctx),
+			},
+		}
+
+		if cfg.DebugLog {
+			s.logger.Info(
+				fmt.Sprintf("stream client call %s", method),
+				append(s.fields,
+					zap.Bool("grpc.client_stream", desc.ClientStreams),
+					zap.Bool("grpc.server_stream", desc.ServerStreams),
+				)...,
+			)
+		}
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			s.finish(err)
+			return nil, err
+		}
+
+		s.ClientStream = cs
+
+		return s, nil
+	}
+}
+
+// SendMsg логирует исходящие сообщения, как запрос unary
+func (s *payloadLoggingClientStream) SendMsg(m any) error {
+	if s.cfg.DebugLog {
+		s.logger.Info(
+			"stream client send",
+			append(s.fields, zap.String("grpc.payload", redact.Proto(m, s.cfg.LogRedactor)))...,
+		)
+	}
+
+	return s.ClientStream.SendMsg(m)
+}
+
+// RecvMsg логирует входящие сообщения, а на io.EOF или ошибке - завершение стрима
+func (s *payloadLoggingClientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		s.finish(nil)
+		return err
+	}
+
+	if err != nil {
+		s.finish(err)
+		return err
+	}
+
+	if s.cfg.DebugLog {
+		s.logger.Info(
+			"stream client recv",
+			append(s.fields, zap.String("grpc.payload", redact.Proto(m, s.cfg.LogRedactor)))...,
+		)
+	}
+
+	return nil
+}
+
+func (s *payloadLoggingClientStream) finish(err error) {
+	if s.finished.Swap(true) {
+		return
+	}
+
+	code := status.Code(err)
+	level := grpczap.DefaultCodeToLevel(code)
+
+	if !s.cfg.DebugLog && !zap.WarnLevel.Enabled(level) {
+		return
+	}
+
+	s.logger.Log(
+		level,
+		fmt.Sprintf("finished stream client call with code %s", code.String()),
+		append(s.fields, zap.String("grpc.code", code.String()), zap.Error(err))...,
+	)
+}
+
+func fieldWithTraceID(ctx context.Context) zap.Field {
+	trCtx := apm.TransactionFromContext(ctx).TraceContext()
+	if trCtx.Trace.Validate() == nil {
+		return zap.String("trace.id", trCtx.Trace.String())
+	}
+	return zap.Skip()
+}
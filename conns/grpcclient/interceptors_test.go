@@ -0,0 +1,185 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type fakeClientStream struct {
+	grpc.ClientStream
+
+	ctx  context.Context
+	sent []any
+	recv []string
+	err  error
+}
+
+func TestErrorInterceptor(t *testing.T) {
+	interceptor := ErrorInterceptor()
+
+	err := interceptor(context.Background(), "/users.Users/Get", nil, nil, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			return status.Error(codes.NotFound, "user not found")
+		})
+
+	var e *ferrors.Error
+	require.True(t, errors.As(err, &e))
+	require.Equal(t, ferrors.NotFound, e.Code)
+
+	require.NoError(t, interceptor(context.Background(), "/users.Users/Get", nil, nil, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		}))
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	interceptor := timeoutInterceptor(time.Minute)
+
+	var deadline time.Time
+	invoker := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		deadline, _ = ctx.Deadline()
+		return nil
+	}
+
+	require.NoError(t, interceptor(context.Background(), "/users.Users/Get", nil, nil, nil, invoker))
+	require.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	// свой дедлайн вызывающего не трогаем
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	require.NoError(t, interceptor(ctx, "/users.Users/Get", nil, nil, nil, invoker))
+	require.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Second)
+}
+
+func TestPayloadLoggingInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	interceptor := payloadLoggingInterceptor(zap.New(core), Config{DebugLog: true}, "users")
+
+	reply := wrapperspb.String("")
+	require.NoError(t, interceptor(context.Background(), "/users.Users/Get", wrapperspb.String("req"), reply, nil,
+		func(_ context.Context, _ string, _, reply any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			reply.(*wrapperspb.StringValue).Value = "resp"
+			return nil
+		}))
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 2)
+	require.Equal(t, `"req"`, entries[0].ContextMap()["grpc.payload"])
+	require.Equal(t, `"resp"`, entries[1].ContextMap()["grpc.payload"])
+	require.Equal(t, "OK", entries[1].ContextMap()["grpc.code"])
+
+	// без DebugLog логируются только ошибки
+	core, logs = observer.New(zapcore.InfoLevel)
+	interceptor = payloadLoggingInterceptor(zap.New(core), Config{}, "users")
+
+	require.NoError(t, interceptor(context.Background(), "/users.Users/Get", wrapperspb.String("req"), reply, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		}))
+	require.Zero(t, logs.Len())
+
+	require.Error(t, interceptor(context.Background(), "/users.Users/Get", wrapperspb.String("req"), reply, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			return status.Error(codes.Internal, "boom")
+		}))
+	require.Equal(t, 1, logs.FilterField(zap.String("grpc.code", "Internal")).Len())
+}
+
+func TestPayloadLoggingStreamInterceptor(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	interceptor := payloadLoggingStreamInterceptor(zap.New(core), Config{DebugLog: true}, "users")
+
+	fake := &fakeClientStream{ctx: context.Background(), recv: []string{"a", "b"}}
+
+	cs, err := interceptor(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/users.Users/List",
+		func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return fake, nil
+		})
+	require.NoError(t, err)
+
+	require.NoError(t, cs.SendMsg(wrapperspb.String("filter")))
+
+	for {
+		msg := wrapperspb.String("")
+		if err = cs.RecvMsg(msg); err != nil {
+			break
+		}
+	}
+
+	require.Equal(t, io.EOF, err)
+	// повторный io.EOF не логирует завершение второй раз
+	require.Equal(t, io.EOF, cs.RecvMsg(wrapperspb.String("")))
+
+	payloads := make([]any, 0, 3)
+	for _, e := range logs.FilterFieldKey("grpc.payload").AllUntimed() {
+		payloads = append(payloads, e.ContextMap()["grpc.payload"])
+	}
+
+	require.Equal(t, []any{`"filter"`, `"a"`, `"b"`}, payloads)
+	require.Equal(t, 1, logs.FilterMessage("finished stream client call with code OK").Len())
+
+	// ошибка открытия стрима логируется и без DebugLog
+	core, logs = observer.New(zapcore.InfoLevel)
+	interceptor = payloadLoggingStreamInterceptor(zap.New(core), Config{}, "users")
+
+	_, err = interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/users.Users/List",
+		func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return nil, status.Error(codes.Unavailable, "no connection")
+		})
+	require.Error(t, err)
+	require.Equal(t, 1, logs.FilterMessage("finished stream client call with code Unavailable").Len())
+}
+
+func TestNewServiceConfig(t *testing.T) {
+	sc := newServiceConfig(ClientConfig{MaxAttempts: 1})
+	require.Empty(t, sc.MethodConfig)
+
+	sc = newServiceConfig(ClientConfig{
+		MaxAttempts:  3,
+		RetryBackoff: 100 * time.Millisecond,
+		RetryCodes:   []string{"UNAVAILABLE"},
+	})
+	require.Len(t, sc.MethodConfig, 1)
+	require.Equal(t, 3, sc.MethodConfig[0].RetryPolicy.MaxAttempts)
+	require.Equal(t, "0.100s", sc.MethodConfig[0].RetryPolicy.InitialBackoff)
+	require.Equal(t, "1.000s", sc.MethodConfig[0].RetryPolicy.MaxBackoff)
+}
+
+func (s *fakeClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeClientStream) SendMsg(m any) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+func (s *fakeClientStream) RecvMsg(m any) error {
+	if len(s.recv) == 0 {
+		if s.err != nil {
+			return s.err
+		}
+
+		return io.EOF
+	}
+
+	m.(*wrapperspb.StringValue).Value = s.recv[0]
+	s.recv = s.recv[1:]
+
+	return nil
+}
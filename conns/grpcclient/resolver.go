@@ -0,0 +1,151 @@
+package grpcclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nenormalka/freya/conns/consul"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	consulScheme = "consul"
+)
+
+var (
+	errEmptyServiceName = errors.New("empty consul service name")
+	errNoConsul         = errors.New("consul is not configured")
+)
+
+type (
+	// consulBuilder резолвит адреса вида consul://service_name?tag=tag1 через ServiceDiscovery
+	consulBuilder struct {
+		sd       consul.ServiceDiscovery
+		interval time.Duration
+		logger   *zap.Logger
+	}
+
+	consulResolver struct {
+		sd       consul.ServiceDiscovery
+		cc       resolver.ClientConn
+		service  string
+		tags     []string
+		interval time.Duration
+		logger   *zap.Logger
+
+		resolveNow chan struct{}
+		cancel     context.CancelFunc
+		wg         sync.WaitGroup
+	}
+)
+
+func newConsulBuilder(sd consul.ServiceDiscovery, interval time.Duration, logger *zap.Logger) *consulBuilder {
+	return &consulBuilder{
+		sd:       sd,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+func (b *consulBuilder) Scheme() string {
+	return consulScheme
+}
+
+func (b *consulBuilder) Build(
+	target resolver.Target,
+	cc resolver.ClientConn,
+	_ resolver.BuildOptions,
+) (resolver.Resolver, error) {
+	service := target.URL.Host
+	if service == "" {
+		service = strings.TrimPrefix(target.URL.Path, "/")
+	}
+
+	if service == "" {
+		return nil, errEmptyServiceName
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r := &consulResolver{
+		sd:         b.sd,
+		cc:         cc,
+		service:    service,
+		tags:       target.URL.Query()["tag"],
+		interval:   b.interval,
+		logger:     b.logger,
+		resolveNow: make(chan struct{}, 1),
+		cancel:     cancel,
+	}
+
+	r.wg.Add(1)
+	go r.watch(ctx)
+
+	return r, nil
+}
+
+func (r *consulResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+func (r *consulResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *consulResolver) watch(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.resolve(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.resolveNow:
+		}
+	}
+}
+
+func (r *consulResolver) resolve(ctx context.Context) {
+	entries, err := r.sd.ServiceInfo(ctx, r.service, r.tags)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Error("failed to resolve grpc target", zap.String("service", r.service), zap.Error(err))
+			r.cc.ReportError(fmt.Errorf("resolve consul service %s: %w", r.service, err))
+		}
+
+		return
+	}
+
+	addrs := make([]resolver.Address, 0, len(entries))
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+
+		addrs = append(addrs, resolver.Address{
+			Addr: net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)),
+		})
+	}
+
+	if err = r.cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		r.logger.Warn("failed to update grpc resolver state", zap.String("service", r.service), zap.Error(err))
+	}
+}
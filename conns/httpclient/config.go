@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nenormalka/freya/config"
+)
+
+const (
+	defaultRetryBackoff        = 100 * time.Millisecond
+	defaultMaxIdleConns        = 100
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+type (
+	Config struct {
+		Clients  []ClientConfig
+		DebugLog bool
+		// RedactKeys ключи json тел, значения которых вычищаются из логов, общие с http сервером
+		RedactKeys []string
+	}
+
+	ClientConfig struct {
+		Name                string
+		BaseURL             string
+		Timeout             time.Duration
+		MaxAttempts         int
+		RetryBackoff        time.Duration
+		MaxIdleConns        int
+		MaxIdleConnsPerHost int
+		IdleConnTimeout     time.Duration
+		Headers             map[string]string
+	}
+)
+
+func NewConfig(cfg *config.Config) Config {
+	clients := make([]ClientConfig, 0, len(cfg.HTTPClients))
+
+	for _, c := range cfg.HTTPClients {
+		client := ClientConfig{
+			Name:                c.Name,
+			BaseURL:             c.BaseURL,
+			Timeout:             c.Timeout,
+			MaxAttempts:         c.MaxAttempts,
+			RetryBackoff:        c.RetryBackoff,
+			MaxIdleConns:        c.MaxIdleConns,
+			MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+			IdleConnTimeout:     c.IdleConnTimeout,
+			Headers:             c.Headers,
+		}
+
+		if client.RetryBackoff == 0 {
+			client.RetryBackoff = defaultRetryBackoff
+		}
+
+		if client.MaxIdleConns == 0 {
+			client.MaxIdleConns = defaultMaxIdleConns
+		}
+
+		if client.MaxIdleConnsPerHost == 0 {
+			client.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+		}
+
+		if client.IdleConnTimeout == 0 {
+			client.IdleConnTimeout = defaultIdleConnTimeout
+		}
+
+		clients = append(clients, client)
+	}
+
+	redactKeys := make([]string, 0)
+	for _, key := range strings.Split(cfg.HTTP.LogRedactKeys, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			redactKeys = append(redactKeys, key)
+		}
+	}
+
+	return Config{
+		Clients:    clients,
+		DebugLog:   cfg.DebugLog,
+		RedactKeys: redactKeys,
+	}
+}
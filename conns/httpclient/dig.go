@@ -0,0 +1,10 @@
+package httpclient
+
+import (
+	"github.com/nenormalka/freya/types"
+)
+
+var Module = types.Module{
+	{CreateFunc: NewConfig},
+	{CreateFunc: NewHTTPClients},
+}
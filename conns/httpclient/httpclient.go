@@ -0,0 +1,115 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"go.elastic.co/apm/module/apmhttp/v2"
+	"go.uber.org/dig"
+	"go.uber.org/zap"
+)
+
+type (
+	Params struct {
+		dig.In
+
+		Config Config
+		Logger *zap.Logger
+	}
+
+	// Client http клиент апстрима. Относительные пути резолвятся от BaseURL, так что работают и
+	// обычные методы http.Client вроде Get.
+	Client struct {
+		*http.Client
+
+		name string
+	}
+
+	routeKey struct{}
+)
+
+// NewHTTPClients создаёт клиентов для всех апстримов из конфига
+func NewHTTPClients(p Params) (map[string]*Client, error) {
+	if len(p.Config.Clients) == 0 {
+		return nil, nil
+	}
+
+	clients := make(map[string]*Client, len(p.Config.Clients))
+
+	for _, client := range p.Config.Clients {
+		c, err := NewClient(p.Config, client, p.Logger)
+		if err != nil {
+			return nil, fmt.Errorf("create http client %s: %w", client.Name, err)
+		}
+
+		clients[client.Name] = c
+	}
+
+	return clients, nil
+}
+
+// NewClient собирает клиента с apm, метриками, логированием, ретраями и заголовками по умолчанию.
+// Пригодится, если клиента нужно создать самостоятельно.
+func NewClient(cfg Config, client ClientConfig, logger *zap.Logger) (*Client, error) {
+	var (
+		baseURL *url.URL
+		err     error
+	)
+
+	// без паузы ретраи крутились бы вхолостую
+	if client.RetryBackoff <= 0 {
+		client.RetryBackoff = defaultRetryBackoff
+	}
+
+	if client.BaseURL != "" {
+		if baseURL, err = url.Parse(client.BaseURL); err != nil {
+			return nil, fmt.Errorf("parse base url: %w", err)
+		}
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.MaxIdleConns = client.MaxIdleConns
+	base.MaxIdleConnsPerHost = client.MaxIdleConnsPerHost
+	base.IdleConnTimeout = client.IdleConnTimeout
+
+	return &Client{
+		Client: &http.Client{
+			Timeout: client.Timeout,
+			Transport: &transport{
+				next:       apmhttp.WrapRoundTripper(base),
+				client:     client,
+				baseURL:    baseURL,
+				logger:     logger.Named("http_client"),
+				debugLog:   cfg.DebugLog,
+				redactKeys: cfg.RedactKeys,
+			},
+		},
+		name: client.Name,
+	}, nil
+}
+
+// NewRequest создаёт запрос с именем роута для метрик. path может быть относительным
+func (c *Client) NewRequest(ctx context.Context, method, route, path string, body io.Reader) (*http.Request, error) {
+	return http.NewRequestWithContext(WithRoute(ctx, route), method, path, body)
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+// WithRoute задаёт имя роута, под которым запрос попадёт в метрики. Без него используется метод запроса,
+// путь не подставляется, чтобы идентификаторы в нём не раздували количество серий.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+func routeFromContext(req *http.Request) string {
+	if route, ok := req.Context().Value(routeKey{}).(string); ok && route != "" {
+		return route
+	}
+
+	return req.Method
+}
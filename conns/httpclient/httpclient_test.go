@@ -0,0 +1,66 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestClientRetry(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/users/1", r.URL.Path)
+		require.Equal(t, "freya", r.Header.Get("X-Client"))
+
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(Config{}, ClientConfig{
+		Name:         "users",
+		BaseURL:      srv.URL + "/v1/",
+		MaxAttempts:  3,
+		RetryBackoff: time.Millisecond,
+		Headers:      map[string]string{"X-Client": "freya"},
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "get_user", "users/1", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int32(3), calls.Load())
+
+	// POST не идемпотентный, второй попытки нет
+	calls.Store(0)
+	resp, err = client.Post("users/1", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestClientDefaultRetryBackoff(t *testing.T) {
+	client, err := NewClient(Config{}, ClientConfig{Name: "users", MaxAttempts: 3}, zap.NewNop())
+	require.NoError(t, err)
+
+	require.Equal(t, defaultRetryBackoff, client.Transport.(*transport).client.RetryBackoff)
+}
@@ -0,0 +1,264 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nenormalka/freya/logger/redact"
+	"github.com/nenormalka/freya/types"
+
+	"go.elastic.co/apm/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	retryBackoffMultiplier = 2
+	maxBackoffFactor       = 10
+)
+
+type (
+	// transport ретраит, проставляет заголовки и base url, пишет метрики и логи. Каждая попытка
+	// идёт через apmhttp и получает свой span.
+	transport struct {
+		next       http.RoundTripper
+		client     ClientConfig
+		baseURL    *url.URL
+		logger     *zap.Logger
+		debugLog   bool
+		redactKeys []string
+	}
+
+	readCloser struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+var (
+	errServerStatus = errors.New("server error status")
+
+	idempotentMethods = map[string]struct{}{
+		http.MethodGet:     {},
+		http.MethodHead:    {},
+		http.MethodOptions: {},
+		http.MethodTrace:   {},
+		http.MethodPut:     {},
+		http.MethodDelete:  {},
+	}
+
+	retryStatuses = map[int]struct{}{
+		http.StatusBadGateway:         {},
+		http.StatusServiceUnavailable: {},
+		http.StatusGatewayTimeout:     {},
+	}
+)
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = t.prepare(req)
+	route := routeFromContext(req)
+
+	fields := []zap.Field{
+		zap.String("http.client", t.client.Name),
+		zap.String("http.route", route),
+		zap.String("http.method", req.Method),
+		zap.String("http.url", req.URL.Redacted()),
+		fieldWithTraceID(req),
+	}
+
+	if t.debugLog {
+		t.logger.Info(
+			fmt.Sprintf("http client call %s %s", req.Method, route),
+			append(fields, zap.String("http.payload", t.requestBody(req)))...,
+		)
+	}
+
+	var resp *http.Response
+
+	err := types.WithHTTPMetrics(t.client.Name+"."+route, func() error {
+		var err error
+		if resp, err = t.roundTripWithRetry(req); err != nil {
+			return err
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return errServerStatus
+		}
+
+		return nil
+	})
+
+	if resp == nil {
+		t.logger.Warn("finished http client call with error", append(fields, zap.Error(err))...)
+		return nil, err
+	}
+
+	level := statusToLevel(resp.StatusCode)
+	if !t.debugLog && !zap.WarnLevel.Enabled(level) {
+		return resp, nil
+	}
+
+	responseField := zap.Skip()
+	if t.debugLog {
+		responseField = zap.String("http.payload", t.responseBody(resp))
+	}
+
+	t.logger.Log(
+		level,
+		fmt.Sprintf("finished http client call with code %d", resp.StatusCode),
+		append(fields, zap.Int("http.code", resp.StatusCode), responseField)...,
+	)
+
+	return resp, nil
+}
+
+func (t *transport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// prepare клонирует запрос, RoundTripper не должен менять чужой запрос
+func (t *transport) prepare(req *http.Request) *http.Request {
+	req = req.Clone(req.Context())
+
+	if t.baseURL != nil && req.URL.Host == "" {
+		req.URL = t.baseURL.ResolveReference(req.URL)
+		req.Host = ""
+	}
+
+	for key, value := range t.client.Headers {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+
+	return req
+}
+
+func (t *transport) roundTripWithRetry(req *http.Request) (*http.Response, error) {
+	attempts := t.client.MaxAttempts
+	if attempts < 1 || !retryable(req) {
+		attempts = 1
+	}
+
+	backoff := t.client.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewind request body: %w", err)
+			}
+
+			req.Body = body
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= attempts || !shouldRetry(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if backoff *= retryBackoffMultiplier; backoff > t.client.RetryBackoff*maxBackoffFactor {
+			backoff = t.client.RetryBackoff * maxBackoffFactor
+		}
+	}
+}
+
+// retryable ретраятся только идемпотентные методы, тело которых можно перечитать
+func retryable(req *http.Request) bool {
+	if _, ok := idempotentMethods[req.Method]; !ok {
+		return false
+	}
+
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	_, ok := retryStatuses[resp.StatusCode]
+
+	return ok
+}
+
+func (t *transport) requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "read: " + err.Error()
+		}
+		defer body.Close()
+
+		buf, err := io.ReadAll(io.LimitReader(body, redact.MaxBody+1))
+		if err != nil {
+			return "read: " + err.Error()
+		}
+
+		return redact.Body(buf, t.redactKeys)
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, redact.MaxBody+1))
+	req.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), req.Body), Closer: req.Body}
+
+	if err != nil {
+		return "read: " + err.Error()
+	}
+
+	return redact.Body(buf, t.redactKeys)
+}
+
+// responseBody вычитывает начало тела для лога и подкладывает его обратно
+func (t *transport) responseBody(resp *http.Response) string {
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, redact.MaxBody+1))
+	resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), resp.Body), Closer: resp.Body}
+
+	if err != nil {
+		return "read: " + err.Error()
+	}
+
+	return redact.Body(buf, t.redactKeys)
+}
+
+func statusToLevel(code int) zapcore.Level {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return zap.ErrorLevel
+	case code >= http.StatusBadRequest:
+		return zap.WarnLevel
+	default:
+		return zap.InfoLevel
+	}
+}
+
+func fieldWithTraceID(req *http.Request) zap.Field {
+	trCtx := apm.TransactionFromContext(req.Context()).TraceContext()
+	if trCtx.Trace.Validate() == nil {
+		return zap.String("trace.id", trCtx.Trace.String())
+	}
+	return zap.Skip()
+}
@@ -1,9 +1,12 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
 
 	"github.com/nenormalka/freya/conns/kafka/common"
@@ -14,6 +17,10 @@ import (
 	"go.uber.org/zap"
 )
 
+var (
+	errNoAddresses = errors.New("no kafka addresses")
+)
+
 type (
 	ConsumerGroup interface {
 		AddHandler(topic common.Topic, hm common.MessageHandler) error
@@ -67,6 +74,30 @@ func (k *Kafka) NewSyncProducer(opts ...syncproducer.SyncProducerOption) (SyncPr
 	return sp, nil
 }
 
+// Ping проверяет, что доступен хотя бы один брокер из конфига
+func (k *Kafka) Ping(ctx context.Context) error {
+	if len(k.cfg.Addresses) == 0 {
+		return errNoAddresses
+	}
+
+	var (
+		d    net.Dialer
+		errs []error
+	)
+
+	for _, addr := range k.cfg.Addresses {
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		return conn.Close()
+	}
+
+	return fmt.Errorf("kafka: no available brokers: %w", errors.Join(errs...))
+}
+
 func AddTypedHandler[T any](
 	cg ConsumerGroup,
 	topic common.Topic,
@@ -0,0 +1,255 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/nenormalka/freya/types"
+
+	"github.com/georgysavva/scany/dbscan"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type (
+	// PgxCopier PgxConn из CallContext и PgxTx из CallTransaction
+	PgxCopier interface {
+		CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	}
+
+	PgxBatcher interface {
+		SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	}
+
+	// Batch запросы, которые уходят в базу одним походом. Результат каждого забирается из
+	// BatchResult, который вернул Queue*, после SendBatch.
+	Batch struct {
+		batch   *pgx.Batch
+		readers []func(br pgx.BatchResults) error
+	}
+
+	BatchResult[T any] struct {
+		value T
+		err   error
+	}
+
+	structColumns struct {
+		names   []string
+		indexes [][]int
+	}
+
+	metricsServiceKey struct{}
+)
+
+var (
+	ErrNotStruct     = errors.New("rows must be structs or pointers to structs")
+	ErrBatchNotSent  = errors.New("batch is not sent")
+	structColumnsMap sync.Map
+)
+
+// CopyFrom вставляет rows в table через COPY. Колонки берутся из тегов db, поля без тега переводятся
+// в snake_case, как при Select и Get, `db:"-"` пропускается.
+func CopyFrom[T any](ctx context.Context, db PgxCopier, queryName, table string, rows []T) (int64, error) {
+	cols, err := columnsOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return 0, err
+	}
+
+	var copied int64
+
+	err = types.WithSQLMetrics(queryName, metricsService(ctx), func() error {
+		var err error
+
+		copied, err = db.CopyFrom(
+			ctx,
+			pgx.Identifier(strings.Split(table, ".")),
+			cols.names,
+			pgx.CopyFromSlice(len(rows), func(i int) ([]any, error) {
+				return cols.values(reflect.ValueOf(rows[i]))
+			}),
+		)
+
+		return err
+	})
+
+	return copied, err
+}
+
+func NewBatch() *Batch {
+	return &Batch{
+		batch: &pgx.Batch{},
+	}
+}
+
+func (b *Batch) Len() int {
+	return b.batch.Len()
+}
+
+// QueueSelect добавляет запрос, строки которого сканируются в []T
+func QueueSelect[T any](b *Batch, query string, args ...any) *BatchResult[[]T] {
+	res := &BatchResult[[]T]{err: ErrBatchNotSent}
+
+	b.queue(query, args, func(br pgx.BatchResults) error {
+		rows, err := br.Query()
+		if err != nil {
+			res.err = err
+			return err
+		}
+
+		res.err = pgxscan.ScanAll(&res.value, rows)
+
+		return res.err
+	})
+
+	return res
+}
+
+// QueueGet добавляет запрос, единственная строка которого сканируется в T, без строк - pgx.ErrNoRows
+func QueueGet[T any](b *Batch, query string, args ...any) *BatchResult[T] {
+	res := &BatchResult[T]{err: ErrBatchNotSent}
+
+	b.queue(query, args, func(br pgx.BatchResults) error {
+		rows, err := br.Query()
+		if err != nil {
+			res.err = err
+			return err
+		}
+
+		res.err = pgxscan.ScanOne(&res.value, rows)
+		if pgxscan.NotFound(res.err) {
+			res.err = pgx.ErrNoRows
+		}
+
+		return res.err
+	})
+
+	return res
+}
+
+// QueueExec добавляет запрос без результата
+func QueueExec(b *Batch, query string, args ...any) *BatchResult[pgconn.CommandTag] {
+	res := &BatchResult[pgconn.CommandTag]{err: ErrBatchNotSent}
+
+	b.queue(query, args, func(br pgx.BatchResults) error {
+		res.value, res.err = br.Exec()
+		return res.err
+	})
+
+	return res
+}
+
+// SendBatch отправляет запросы и раскладывает результаты, возвращает первую ошибку.
+// В транзакции CallTransaction батч выполняется внутри неё, иначе постгря сама оборачивает его в транзакцию.
+func SendBatch(ctx context.Context, db PgxBatcher, queryName string, b *Batch) error {
+	return types.WithSQLMetrics(queryName, metricsService(ctx), func() error {
+		br := db.SendBatch(ctx, b.batch)
+
+		var firstErr error
+
+		for _, read := range b.readers {
+			if err := read(br); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		if err := br.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		return firstErr
+	})
+}
+
+func (b *Batch) queue(query string, args []any, read func(br pgx.BatchResults) error) {
+	b.batch.Queue(query, args...)
+	b.readers = append(b.readers, read)
+}
+
+// Value результат запроса, доступен после SendBatch
+func (r *BatchResult[T]) Value() (T, error) {
+	return r.value, r.err
+}
+
+func columnsOf(t reflect.Type) (*structColumns, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s: %w", t, ErrNotStruct)
+	}
+
+	if cols, ok := structColumnsMap.Load(t); ok {
+		return cols.(*structColumns), nil
+	}
+
+	cols := &structColumns{}
+	collectColumns(t, nil, cols)
+
+	structColumnsMap.Store(t, cols)
+
+	return cols, nil
+}
+
+func collectColumns(t reflect.Type, parent []int, cols *structColumns) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag, hasTag := field.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+
+		index := append(append([]int{}, parent...), i)
+
+		// встроенные структуры без тега раскладываются на свои поля, даже если сам тип неэкспортируемый
+		if field.Anonymous && !hasTag && field.Type.Kind() == reflect.Struct {
+			collectColumns(field.Type, index, cols)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := tag
+		if !hasTag {
+			name = dbscan.SnakeCaseMapper(field.Name)
+		}
+
+		cols.names = append(cols.names, name)
+		cols.indexes = append(cols.indexes, index)
+	}
+}
+
+func (c *structColumns) values(v reflect.Value) ([]any, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, fmt.Errorf("nil row: %w", ErrNotStruct)
+		}
+
+		v = v.Elem()
+	}
+
+	values := make([]any, len(c.indexes))
+	for i, index := range c.indexes {
+		values[i] = v.FieldByIndex(index).Interface()
+	}
+
+	return values, nil
+}
+
+func withMetricsService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, metricsServiceKey{}, service)
+}
+
+// metricsService label service для метрик CopyFrom, SendBatch и потоковых запросов, его проставляют коннекторы
+func metricsService(ctx context.Context) string {
+	service, _ := ctx.Value(metricsServiceKey{}).(string)
+	return service
+}
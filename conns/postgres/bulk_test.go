@@ -0,0 +1,260 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	dbtypes "github.com/nenormalka/freya/conns/postgres/types"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+)
+
+type copyRecorder struct {
+	table   pgx.Identifier
+	columns []string
+	rows    [][]any
+}
+
+func (c *copyRecorder) CopyFrom(
+	_ context.Context,
+	tableName pgx.Identifier,
+	columnNames []string,
+	rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	c.table, c.columns = tableName, columnNames
+
+	for rowSrc.Next() {
+		values, err := rowSrc.Values()
+		if err != nil {
+			return 0, err
+		}
+
+		c.rows = append(c.rows, values)
+	}
+
+	return int64(len(c.rows)), rowSrc.Err()
+}
+
+func TestCopyFrom(t *testing.T) {
+	type audit struct {
+		CreatedAt time.Time
+	}
+
+	type user struct {
+		ID       int64  `db:"id"`
+		FullName string // full_name
+		Password string `db:"-"`
+		internal string
+		audit
+	}
+
+	now := time.Now()
+	rec := &copyRecorder{}
+
+	copied, err := CopyFrom(context.Background(), rec, "copy_users", "public.users", []*user{
+		{ID: 1, FullName: "Freya", Password: "secret", audit: audit{CreatedAt: now}},
+		{ID: 2, FullName: "Odin", internal: "skip", audit: audit{CreatedAt: now}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), copied)
+	require.Equal(t, pgx.Identifier{"public", "users"}, rec.table)
+	require.Equal(t, []string{"id", "full_name", "created_at"}, rec.columns)
+	require.Equal(t, [][]any{{int64(1), "Freya", now}, {int64(2), "Odin", now}}, rec.rows)
+
+	_, err = CopyFrom(context.Background(), rec, "copy_ids", "ids", []int64{1, 2})
+	require.ErrorIs(t, err, ErrNotStruct)
+}
+
+type (
+	// fakeBatch отвечает на запросы батча по порядку заранее заданными результатами
+	fakeBatch struct {
+		results []fakeResult
+		closed  bool
+	}
+
+	fakeResult struct {
+		columns []string
+		rows    [][]any
+		tag     pgconn.CommandTag
+		err     error
+	}
+
+	fakeRows struct {
+		columns []string
+		rows    [][]any
+		i       int
+	}
+)
+
+func TestSendBatch(t *testing.T) {
+	type user struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+
+	b := NewBatch()
+	users := QueueSelect[user](b, "SELECT id, name FROM users")
+	count := QueueGet[int64](b, "SELECT count(*) FROM users")
+	updated := QueueExec(b, "UPDATE users SET name = $1", "Freya")
+	require.Equal(t, 3, b.Len())
+
+	// до отправки результатов нет
+	_, err := users.Value()
+	require.ErrorIs(t, err, ErrBatchNotSent)
+	_, err = updated.Value()
+	require.ErrorIs(t, err, ErrBatchNotSent)
+
+	batch := &fakeBatch{results: []fakeResult{
+		{columns: []string{"id", "name"}, rows: [][]any{{int64(1), "Freya"}, {int64(2), "Odin"}}},
+		{columns: []string{"count"}, rows: [][]any{{int64(2)}}},
+		{tag: pgconn.CommandTag("UPDATE 2")},
+	}}
+
+	require.NoError(t, SendBatch(context.Background(), batch, "batch_users", b))
+	require.True(t, batch.closed)
+
+	us, err := users.Value()
+	require.NoError(t, err)
+	require.Equal(t, []user{{ID: 1, Name: "Freya"}, {ID: 2, Name: "Odin"}}, us)
+
+	c, err := count.Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), c)
+
+	tag, err := updated.Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), tag.RowsAffected())
+}
+
+func TestSendBatchErrors(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+
+	b := NewBatch()
+	missing := QueueGet[int64](b, "SELECT id FROM users WHERE id = $1", 42)
+	failed := QueueExec(b, "UPDATE users SET name = NULL")
+	broken := QueueSelect[int64](b, "SELECT id FROM broken")
+	ok := QueueExec(b, "DELETE FROM sessions")
+
+	batch := &fakeBatch{results: []fakeResult{
+		{columns: []string{"id"}},
+		{err: errFirst},
+		{err: errSecond},
+		{tag: pgconn.CommandTag("DELETE 1")},
+	}}
+
+	// возвращается первая ошибка, остальные результаты всё равно раскладываются
+	err := SendBatch(context.Background(), batch, "batch_errors", b)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	require.NotErrorIs(t, err, errFirst)
+
+	_, err = missing.Value()
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = failed.Value()
+	require.ErrorIs(t, err, errFirst)
+
+	_, err = broken.Value()
+	require.ErrorIs(t, err, errSecond)
+
+	tag, err := ok.Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), tag.RowsAffected())
+
+	// батч через PgxConn, querier которого не умеет батчи
+	b = NewBatch()
+	notSent := QueueExec(b, "DELETE FROM sessions")
+
+	require.ErrorIs(t, SendBatch(context.Background(), dbtypes.PgxConn{}, "batch_unsupported", b), dbtypes.ErrNotSupported)
+
+	_, err = notSent.Value()
+	require.ErrorIs(t, err, dbtypes.ErrNotSupported)
+}
+
+func (b *fakeBatch) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return b
+}
+
+func (b *fakeBatch) next() fakeResult {
+	res := b.results[0]
+	b.results = b.results[1:]
+
+	return res
+}
+
+func (b *fakeBatch) Exec() (pgconn.CommandTag, error) {
+	res := b.next()
+	return res.tag, res.err
+}
+
+func (b *fakeBatch) Query() (pgx.Rows, error) {
+	res := b.next()
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	return &fakeRows{columns: res.columns, rows: res.rows}, nil
+}
+
+func (b *fakeBatch) QueryRow() pgx.Row {
+	panic("not implemented")
+}
+
+func (b *fakeBatch) QueryFunc([]any, func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	panic("not implemented")
+}
+
+func (b *fakeBatch) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (r *fakeRows) Close() {}
+
+func (r *fakeRows) Err() error {
+	return nil
+}
+
+func (r *fakeRows) CommandTag() pgconn.CommandTag {
+	return nil
+}
+
+func (r *fakeRows) FieldDescriptions() []pgproto3.FieldDescription {
+	fields := make([]pgproto3.FieldDescription, 0, len(r.columns))
+	for _, c := range r.columns {
+		fields = append(fields, pgproto3.FieldDescription{Name: []byte(c)})
+	}
+
+	return fields
+}
+
+func (r *fakeRows) Next() bool {
+	if r.i >= len(r.rows) {
+		return false
+	}
+
+	r.i++
+
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		reflect.ValueOf(d).Elem().Set(reflect.ValueOf(r.rows[r.i-1][i]))
+	}
+
+	return nil
+}
+
+func (r *fakeRows) Values() ([]any, error) {
+	return r.rows[r.i-1], nil
+}
+
+func (r *fakeRows) RawValues() [][]byte {
+	return nil
+}
@@ -9,6 +9,15 @@ import (
 type (
 	PostgresConfig struct {
 		Configs []DBConfig
+		Routes  []RouteConfig
+	}
+
+	RouteConfig struct {
+		Name          string
+		Primary       string
+		Replicas      []string
+		Balancer      Balancer
+		CheckInterval time.Duration
 	}
 
 	DBConfig struct {
@@ -22,11 +31,36 @@ type (
 	}
 )
 
+const (
+	defaultRouteCheckInterval = 5 * time.Second
+)
+
 func NewPostgresConfig(cfg *config.Config) PostgresConfig {
 	if len(cfg.DB) == 0 {
 		return PostgresConfig{}
 	}
 
+	routes := make([]RouteConfig, 0, len(cfg.DBRoutes))
+	for _, r := range cfg.DBRoutes {
+		route := RouteConfig{
+			Name:          r.Name,
+			Primary:       r.Primary,
+			Replicas:      r.Replicas,
+			Balancer:      Balancer(r.Balancer),
+			CheckInterval: r.CheckInterval,
+		}
+
+		if route.Balancer == "" {
+			route.Balancer = BalancerRoundRobin
+		}
+
+		if route.CheckInterval == 0 {
+			route.CheckInterval = defaultRouteCheckInterval
+		}
+
+		routes = append(routes, route)
+	}
+
 	cfgs := make([]DBConfig, len(cfg.DB))
 
 	for i := range cfg.DB {
@@ -42,5 +76,6 @@ func NewPostgresConfig(cfg *config.Config) PostgresConfig {
 
 	return PostgresConfig{
 		Configs: cfgs,
+		Routes:  routes,
 	}
 }
@@ -2,6 +2,8 @@ package postrgres
 
 import (
 	"github.com/nenormalka/freya/types"
+
+	"go.uber.org/dig"
 )
 
 var Module = types.Module{
@@ -11,4 +13,44 @@ var Module = types.Module{
 	{CreateFunc: NewGoQuConnector},
 	{CreateFunc: NewPGXPoolConn},
 	{CreateFunc: NewPGXPool},
+	{CreateFunc: NewErrorClassifier},
+	{CreateFunc: NewRoutes},
+	{CreateFunc: NewUnitOfWork},
+	{CreateFunc: RoutesAdapter},
+	{CreateFunc: NewSubscriber},
+	{CreateFunc: SubscriberAdapter},
+}
+
+type (
+	RoutesAdapterOut struct {
+		dig.Out
+
+		Services []types.Runnable `group:"services,flatten"`
+	}
+
+	SubscriberAdapterOut struct {
+		dig.Out
+
+		Services []types.Runnable `group:"services,flatten"`
+	}
+)
+
+func RoutesAdapter(routes *Routes) RoutesAdapterOut {
+	if routes == nil {
+		return RoutesAdapterOut{}
+	}
+
+	return RoutesAdapterOut{
+		Services: []types.Runnable{routes},
+	}
+}
+
+func SubscriberAdapter(subscriber *Subscriber) SubscriberAdapterOut {
+	if subscriber == nil {
+		return SubscriberAdapterOut{}
+	}
+
+	return SubscriberAdapterOut{
+		Services: []types.Runnable{subscriber},
+	}
 }
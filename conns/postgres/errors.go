@@ -0,0 +1,108 @@
+package postrgres
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.uber.org/dig"
+)
+
+const (
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+
+	duplicateReason  = "duplicate key"
+	foreignKeyReason = "foreign key violation"
+)
+
+type (
+	ErrorClassifierOut struct {
+		dig.Out
+
+		Classifier ferrors.Classifier `group:"error_classifiers"`
+	}
+
+	// publicError отдаёт клиенту общий текст, а исходная ошибка постгри остаётся доступна через
+	// errors.As и попадает только в логи сервера
+	publicError struct {
+		public error
+		err    error
+	}
+)
+
+var (
+	ErrAlreadyExists       = errors.New("already exists")
+	ErrForeignKeyViolation = errors.New(foreignKeyReason)
+)
+
+func NewErrorClassifier() ErrorClassifierOut {
+	return ErrorClassifierOut{
+		Classifier: ClassifyError,
+	}
+}
+
+// ClassifyError отсутствие строк -> NotFound, нарушение уникальности -> AlreadyExists,
+// нарушение внешнего ключа -> FailedPrecondition. Текст ошибки заменяется на ErrAlreadyExists и
+// ErrForeignKeyViolation, в детали попадают только колонки ключа: имена ограничений и значения
+// клиенту не отдаются.
+func ClassifyError(err error) *ferrors.Error {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+		return ferrors.NewNotFoundError(err)
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+
+	switch pgErr.Code {
+	case uniqueViolationCode:
+		if field := keyColumns(pgErr.Detail); field != "" {
+			return ferrors.NewAlreadyExistsError(hide(ErrAlreadyExists, err)).AddDetail("field", field)
+		}
+
+		return ferrors.NewAlreadyExistsError(hide(ErrAlreadyExists, err)).AddDetail("reason", duplicateReason)
+	case foreignKeyViolationCode:
+		subject := keyColumns(pgErr.Detail)
+		if subject == "" {
+			subject = pgErr.TableName
+		}
+
+		return ferrors.NewFailedPreconditionError(hide(ErrForeignKeyViolation, err)).
+			WithPreconditionViolation("FOREIGN_KEY", subject, foreignKeyReason)
+	default:
+		return nil
+	}
+}
+
+// keyColumns колонки ключа из Detail вида `Key (email)=(a@example.com) already exists.`, без значений
+func keyColumns(detail string) string {
+	rest, ok := strings.CutPrefix(detail, "Key (")
+	if !ok {
+		return ""
+	}
+
+	columns, _, ok := strings.Cut(rest, ")=(")
+	if !ok {
+		return ""
+	}
+
+	return columns
+}
+
+func hide(public, err error) error {
+	return &publicError{public: public, err: err}
+}
+
+func (e *publicError) Error() string {
+	return e.public.Error()
+}
+
+func (e *publicError) Unwrap() []error {
+	return []error{e.public, e.err}
+}
@@ -0,0 +1,165 @@
+package postrgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	freyahttp "github.com/nenormalka/freya/http"
+	ferrors "github.com/nenormalka/freya/types/errors"
+	"github.com/nenormalka/freya/types/errors/grpc"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      ferrors.Code
+		details   map[string]string
+		violation *grpc.PreconditionFailure_Violation
+	}{
+		{
+			name: "sql no rows",
+			err:  fmt.Errorf("get user: %w", sql.ErrNoRows),
+			code: ferrors.NotFound,
+		},
+		{
+			name: "pgx no rows",
+			err:  pgx.ErrNoRows,
+			code: ferrors.NotFound,
+		},
+		{
+			name: "unique violation",
+			err: &pgconn.PgError{
+				Code:           uniqueViolationCode,
+				ConstraintName: "users_email_key",
+				Detail:         "Key (email)=(user@example.com) already exists.",
+			},
+			code:    ferrors.AlreadyExists,
+			details: map[string]string{"field": "email"},
+		},
+		{
+			name: "unique violation composite key",
+			err: fmt.Errorf("insert: %w", &pgconn.PgError{
+				Code:           uniqueViolationCode,
+				ConstraintName: "orders_user_id_number_key",
+				Detail:         "Key (user_id, number)=(1, 2) already exists.",
+			}),
+			code:    ferrors.AlreadyExists,
+			details: map[string]string{"field": "user_id, number"},
+		},
+		{
+			name: "unique violation without detail",
+			err: &pgconn.PgError{
+				Code:           uniqueViolationCode,
+				ConstraintName: "users_email_key",
+			},
+			code:    ferrors.AlreadyExists,
+			details: map[string]string{"reason": duplicateReason},
+		},
+		{
+			name: "foreign key violation",
+			err: &pgconn.PgError{
+				Code:           foreignKeyViolationCode,
+				TableName:      "orders",
+				ConstraintName: "orders_user_id_fkey",
+				Detail:         `Key (user_id)=(42) is not present in table "users".`,
+			},
+			code: ferrors.FailedPrecondition,
+			violation: &grpc.PreconditionFailure_Violation{
+				Type:        "FOREIGN_KEY",
+				Subject:     "user_id",
+				Description: foreignKeyReason,
+			},
+		},
+		{
+			name: "foreign key violation without detail",
+			err: &pgconn.PgError{
+				Code:           foreignKeyViolationCode,
+				TableName:      "orders",
+				ConstraintName: "orders_user_id_fkey",
+			},
+			code: ferrors.FailedPrecondition,
+			violation: &grpc.PreconditionFailure_Violation{
+				Type:        "FOREIGN_KEY",
+				Subject:     "orders",
+				Description: foreignKeyReason,
+			},
+		},
+		{
+			name: "other pg error",
+			err:  &pgconn.PgError{Code: "40001"},
+		},
+		{
+			name: "plain error",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ClassifyError(tt.err)
+			if tt.code == 0 {
+				require.Nil(t, e)
+				return
+			}
+
+			require.NotNil(t, e)
+			require.Equal(t, tt.code, e.Code)
+			require.ErrorIs(t, e, tt.err)
+
+			if tt.details != nil {
+				require.Equal(t, tt.details, e.Details)
+			}
+
+			if tt.violation != nil {
+				violations := e.GRPCDetails()[0].(*grpc.PreconditionFailure).GetViolations()
+				require.Len(t, violations, 1)
+				require.Equal(t, tt.violation.GetType(), violations[0].GetType())
+				require.Equal(t, tt.violation.GetSubject(), violations[0].GetSubject())
+				require.Equal(t, tt.violation.GetDescription(), violations[0].GetDescription())
+			}
+
+			for _, value := range e.Details {
+				require.NotContains(t, value, "_key")
+			}
+		})
+	}
+}
+
+func TestClassifyErrorHidesPgError(t *testing.T) {
+	pgErr := &pgconn.PgError{
+		Code:           uniqueViolationCode,
+		Message:        `duplicate key value violates unique constraint "users_email_key"`,
+		ConstraintName: "users_email_key",
+		Detail:         "Key (email)=(user@example.com) already exists.",
+	}
+
+	e := ClassifyError(fmt.Errorf("insert user: %w", pgErr))
+
+	// исходная ошибка доступна серверу для логов
+	var got *pgconn.PgError
+	require.ErrorAs(t, e, &got)
+	require.ErrorIs(t, e, ErrAlreadyExists)
+
+	st, ok := status.FromError(ferrors.ErrorToGRPCError(e))
+	require.True(t, ok)
+	require.Equal(t, "already exists", st.Message())
+	require.Equal(t, "already exists", st.Details()[0].(*grpc.ErrorInfo).GetReason())
+
+	pr := freyahttp.NewProblem(e)
+	require.Equal(t, "already exists", pr.Detail)
+
+	e = ClassifyError(&pgconn.PgError{Code: foreignKeyViolationCode, ConstraintName: "orders_user_id_fkey"})
+
+	st, ok = status.FromError(ferrors.ErrorToGRPCError(e))
+	require.True(t, ok)
+	require.Equal(t, foreignKeyReason, st.Message())
+	require.Equal(t, foreignKeyReason, freyahttp.NewProblem(e).Detail)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/nenormalka/freya/config"
 	"github.com/nenormalka/freya/conns/connectors"
+	"github.com/nenormalka/freya/conns/resilience"
 	"github.com/nenormalka/freya/types"
 )
 
@@ -19,6 +20,7 @@ const (
 
 type (
 	GoQuConn struct {
+		name    string
 		db      *sqlx.DB
 		logger  *zap.Logger
 		appName string
@@ -29,15 +31,17 @@ func NewGoQuConnector(
 	poolDB map[string]*sqlx.DB,
 	logger *zap.Logger,
 	cfg *config.Config,
+	policies *resilience.Policies,
 ) map[string]connectors.DBConnector[*goqu.Database, *goqu.TxDatabase] {
 	return newConns[connectors.DBConnector[*goqu.Database, *goqu.TxDatabase]](
 		poolDB,
 		func(nameConn string) connectors.DBConnector[*goqu.Database, *goqu.TxDatabase] {
-			return &GoQuConn{
+			return resilience.Wrap[*goqu.Database, *goqu.TxDatabase](&GoQuConn{
+				name:    nameConn,
 				db:      poolDB[nameConn],
 				logger:  logger,
 				appName: cfg.AppName,
-			}
+			}, policies.Get(CheckName(ConnTypeSQLX, nameConn)))
 		})
 }
 
@@ -46,6 +50,12 @@ func (s *GoQuConn) CallContext(
 	queryName string,
 	callFunc func(ctx context.Context, gq *goqu.Database) error,
 ) error {
+	if InUnitOfWork(ctx, s.name) {
+		return ErrCallContextInUnitOfWork
+	}
+
+	ctx = withMetricsService(ctx, s.appName)
+
 	return types.WithSQLMetrics(queryName, s.appName, func() error {
 		return callFunc(ctx, goqu.New(dialect, s.db))
 	})
@@ -55,23 +65,55 @@ func (s *GoQuConn) CallTransaction(
 	ctx context.Context,
 	txName string,
 	callFunc func(ctx context.Context, gqx *goqu.TxDatabase) error,
+	opts ...connectors.TxOption,
 ) error {
+	ctx = withMetricsService(ctx, s.appName)
+
+	if active := activeTxFromContext(ctx, s.name); active != nil && active.sqlTx != nil {
+		return types.WithSQLMetrics(txName, s.appName, func() error {
+			return active.savepoint(ctx, s.name, func(ctx context.Context, nested *activeTx) error {
+				return callFunc(ctx, goqu.NewTx(dialect, nested.sqlTx))
+			})
+		})
+	}
+
+	mode, retry, err := txSettings(ctx, opts)
+	if err != nil {
+		return err
+	}
+
 	return types.WithSQLMetrics(txName, s.appName, func() error {
-		tx, err := s.db.BeginTxx(ctx, nil)
-		if err != nil {
-			return err
-		}
+		return withTxRetry(ctx, txName, retry, func() error {
+			return s.transaction(ctx, txName, mode, callFunc)
+		})
+	})
+}
 
-		gqx := goqu.NewTx(dialect, tx)
+func (s *GoQuConn) transaction(
+	ctx context.Context,
+	txName string,
+	opts TxOptions,
+	callFunc func(ctx context.Context, gqx *goqu.TxDatabase) error,
+) error {
+	tx, err := s.db.BeginTxx(ctx, opts.sqlOptions())
+	if err != nil {
+		return err
+	}
 
-		if err = callFunc(ctx, gqx); err != nil {
-			if rErr := gqx.Rollback(); rErr != nil {
-				s.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
-			}
+	if err = setDeferrable(ctx, tx.Tx, opts); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	gqx := goqu.NewTx(dialect, tx)
 
-			return err
+	if err = callFunc(ctx, gqx); err != nil {
+		if rErr := gqx.Rollback(); rErr != nil {
+			s.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
 		}
 
-		return gqx.Commit()
-	})
+		return err
+	}
+
+	return gqx.Commit()
 }
@@ -0,0 +1,299 @@
+package postrgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"reflect"
+	"time"
+
+	"github.com/nenormalka/freya/types"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgtype/pgxtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	// Keyset постраничный обход по курсору: каждая страница отдельный короткий запрос
+	// `WHERE column > последнее значение ORDER BY column LIMIT page_size`, так что длинный выгруз
+	// не держит ни соединение, ни снапшот. Колонка должна быть уникальной и с индексом.
+	Keyset[T any, C any] struct {
+		Column   string
+		PageSize int
+		// Cursor значение колонки курсора у строки
+		Cursor func(row T) C
+		// After продолжить после этого значения, nil - с начала
+		After *C
+	}
+
+	// rowsScanner общий вид строк sqlx, goqu и pgx для стриминга
+	rowsScanner interface {
+		Next() bool
+		Err() error
+	}
+)
+
+var (
+	ErrKeysetOrdered = errors.New("keyset: dataset must not have ORDER BY, order is set by the cursor column")
+
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// SQLXRows стримит строки запроса в T, не загружая всю выборку в память. db - *sqlx.DB из CallContext
+// или *sqlx.Tx из CallTransaction. Структуры сканируются по тегам db, остальные типы - как одна колонка.
+func SQLXRows[T any](ctx context.Context, db sqlx.QueryerContext, queryName, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		stream(ctx, queryName, yield, func() (rowsScanner, func(*T) error, func(), error) {
+			rows, err := db.QueryxContext(ctx, query, args...)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			scan := func(t *T) error { return rows.Scan(t) }
+			if isStruct[T]() {
+				scan = func(t *T) error { return rows.StructScan(structTarget(t)) }
+			}
+
+			return rows, scan, func() { _ = rows.Close() }, nil
+		})
+	}
+}
+
+// GoQuRows стримит строки датасета в T. Если у датасета не заданы колонки, они берутся из T, как в ScanStructs.
+func GoQuRows[T any](ctx context.Context, ds *goqu.SelectDataset, queryName string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		stream(ctx, queryName, yield, func() (rowsScanner, func(*T) error, func(), error) {
+			structRows := isStruct[T]()
+			if structRows && ds.GetClauses().IsDefaultSelect() {
+				ds = ds.Select(structTarget(new(T)))
+			}
+
+			scanner, err := ds.Executor().ScannerContext(ctx)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			scan := func(t *T) error { return scanner.ScanVal(t) }
+			if structRows {
+				scan = func(t *T) error { return scanner.ScanStruct(structTarget(t)) }
+			}
+
+			return scanner, scan, func() { _ = scanner.Close() }, nil
+		})
+	}
+}
+
+// PGXRows стримит строки запроса в T, db - PgxConn из CallContext или PgxTx из CallTransaction
+func PGXRows[T any](ctx context.Context, db pgxtype.Querier, queryName, query string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		stream(ctx, queryName, yield, func() (rowsScanner, func(*T) error, func(), error) {
+			rows, err := db.Query(ctx, query, args...)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			scanner := pgxscan.NewRowScanner(rows)
+
+			scan := func(t *T) error { return scanner.Scan(t) }
+			if isStruct[T]() {
+				scan = func(t *T) error { return scanner.Scan(structTarget(t)) }
+			}
+
+			return rows, scan, rows.Close, nil
+		})
+	}
+}
+
+// SQLXKeyset обходит запрос страницами по курсору, query оборачивается в подзапрос
+func SQLXKeyset[T any, C any](
+	ctx context.Context,
+	db sqlx.QueryerContext,
+	queryName, query string,
+	ks Keyset[T, C],
+	args ...any,
+) iter.Seq2[T, error] {
+	return keyset(ks, func(after *C) iter.Seq2[T, error] {
+		q, qArgs := keysetQuery(query, ks, after, args)
+		return SQLXRows[T](ctx, db, queryName, q, qArgs...)
+	})
+}
+
+// GoQuKeyset обходит датасет страницами по курсору. Сортировку задаёт курсор, поэтому датасет с ORDER BY
+// отклоняется с ErrKeysetOrdered, а не перезаписывается молча.
+func GoQuKeyset[T any, C any](
+	ctx context.Context,
+	ds *goqu.SelectDataset,
+	queryName string,
+	ks Keyset[T, C],
+) iter.Seq2[T, error] {
+	if ds.GetClauses().HasOrder() {
+		return func(yield func(T, error) bool) {
+			var zero T
+			yield(zero, ErrKeysetOrdered)
+		}
+	}
+
+	return keyset(ks, func(after *C) iter.Seq2[T, error] {
+		page := ds.Order(goqu.I(ks.Column).Asc()).Limit(uint(ks.PageSize))
+		if after != nil {
+			page = page.Where(goqu.I(ks.Column).Gt(*after))
+		}
+
+		return GoQuRows[T](ctx, page, queryName)
+	})
+}
+
+// PGXKeyset обходит запрос страницами по курсору, query оборачивается в подзапрос
+func PGXKeyset[T any, C any](
+	ctx context.Context,
+	db pgxtype.Querier,
+	queryName, query string,
+	ks Keyset[T, C],
+	args ...any,
+) iter.Seq2[T, error] {
+	return keyset(ks, func(after *C) iter.Seq2[T, error] {
+		q, qArgs := keysetQuery(query, ks, after, args)
+		return PGXRows[T](ctx, db, queryName, q, qArgs...)
+	})
+}
+
+// stream общий цикл: метрика длительности и количества строк на весь обход, проверка контекста на каждой строке.
+// В длительность входят только запрос и чтение строк, время обработки строки вызывающим не считается.
+func stream[T any](
+	ctx context.Context,
+	queryName string,
+	yield func(T, error) bool,
+	open func() (rowsScanner, func(*T) error, func(), error),
+) {
+	var (
+		zero      T
+		rowsCount int
+		elapsed   time.Duration
+		start     = time.Now()
+	)
+
+	err := func() error {
+		rows, scan, closeRows, err := open()
+		if err != nil {
+			return err
+		}
+		defer closeRows()
+
+		for rows.Next() {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+
+			var t T
+			if err = scan(&t); err != nil {
+				return err
+			}
+
+			rowsCount++
+			elapsed += time.Since(start)
+
+			next := yield(t, nil)
+			start = time.Now()
+
+			// обход прервали, это не ошибка
+			if !next {
+				return nil
+			}
+		}
+
+		return rows.Err()
+	}()
+
+	elapsed += time.Since(start)
+
+	types.DBRowsMetrics.WithLabelValues(queryName).Add(float64(rowsCount))
+	types.ObserveSQLMetrics(queryName, metricsService(ctx), elapsed, err)
+
+	if err != nil {
+		yield(zero, err)
+	}
+}
+
+func keyset[T any, C any](ks Keyset[T, C], page func(after *C) iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+
+		if ks.PageSize <= 0 || ks.Column == "" || ks.Cursor == nil {
+			yield(zero, fmt.Errorf("keyset: column, page size and cursor are required"))
+			return
+		}
+
+		after := ks.After
+
+		for {
+			count := 0
+
+			for row, err := range page(after) {
+				if err != nil {
+					yield(zero, err)
+					return
+				}
+
+				if !yield(row, nil) {
+					return
+				}
+
+				cursor := ks.Cursor(row)
+				after = &cursor
+				count++
+			}
+
+			if count < ks.PageSize {
+				return
+			}
+		}
+	}
+}
+
+func keysetQuery[T any, C any](query string, ks Keyset[T, C], after *C, args []any) (string, []any) {
+	column := "keyset." + pgx.Identifier{ks.Column}.Sanitize()
+	q := "SELECT * FROM (" + query + ") AS keyset"
+
+	qArgs := append([]any{}, args...)
+
+	if after != nil {
+		qArgs = append(qArgs, *after)
+		q += fmt.Sprintf(" WHERE %s > $%d", column, len(qArgs))
+	}
+
+	qArgs = append(qArgs, ks.PageSize)
+	q += fmt.Sprintf(" ORDER BY %s LIMIT $%d", column, len(qArgs))
+
+	return q, qArgs
+}
+
+// isStruct сканировать ли T как строку целиком. time.Time и sql.Scanner вроде sql.NullString - одна колонка.
+// Указатель на структуру (SQLXRows[*User]) тоже сканируется как строка.
+func isStruct[T any]() bool {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// structTarget куда сканировать строку: для T указателя на структуру сама структура выделяется здесь
+func structTarget[T any](t *T) any {
+	v := reflect.ValueOf(t).Elem()
+	if v.Kind() != reflect.Pointer {
+		return t
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+
+	return v.Interface()
+}
@@ -0,0 +1,117 @@
+package postrgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLXKeyset(t *testing.T) {
+	type user struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	base := "SELECT id, name FROM users WHERE active = $1"
+
+	mock.ExpectQuery(`SELECT * FROM (`+base+`) AS keyset ORDER BY keyset."id" LIMIT $2`).
+		WithArgs(true, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "freya").AddRow(2, "odin"))
+	mock.ExpectQuery(`SELECT * FROM (`+base+`) AS keyset WHERE keyset."id" > $2 ORDER BY keyset."id" LIMIT $3`).
+		WithArgs(true, int64(2), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "thor"))
+
+	var names []string
+
+	for u, err := range SQLXKeyset(context.Background(), db, "export_users", base, Keyset[user, int64]{
+		Column:   "id",
+		PageSize: 2,
+		Cursor:   func(u user) int64 { return u.ID },
+	}, true) {
+		require.NoError(t, err)
+		names = append(names, u.Name)
+	}
+
+	require.Equal(t, []string{"freya", "odin", "thor"}, names)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLXRowsCancel(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	mock.ExpectQuery("SELECT id FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		ids     []int64
+		lastErr error
+	)
+
+	for id, err := range SQLXRows[int64](ctx, db, "user_ids", "SELECT id FROM users") {
+		if err != nil {
+			lastErr = err
+			break
+		}
+
+		ids = append(ids, id)
+		cancel()
+	}
+
+	require.Equal(t, []int64{1}, ids)
+	require.ErrorIs(t, lastErr, context.Canceled)
+}
+
+func TestSQLXRowsPointer(t *testing.T) {
+	type user struct {
+		ID   int64  `db:"id"`
+		Name string `db:"name"`
+	}
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	mock.ExpectQuery("SELECT id, name FROM users").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "freya").AddRow(2, "odin"))
+
+	var users []*user
+
+	for u, err := range SQLXRows[*user](context.Background(), db, "users", "SELECT id, name FROM users") {
+		require.NoError(t, err)
+		users = append(users, u)
+	}
+
+	// на каждую строку своя структура
+	require.Equal(t, []*user{{ID: 1, Name: "freya"}, {ID: 2, Name: "odin"}}, users)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGoQuKeysetOrdered(t *testing.T) {
+	ds := goqu.Dialect("postgres").From("users").Order(goqu.C("name").Asc())
+
+	var errs []error
+
+	for _, err := range GoQuKeyset(context.Background(), ds, "users", Keyset[int64, int64]{
+		Column:   "id",
+		PageSize: 10,
+		Cursor:   func(id int64) int64 { return id },
+	}) {
+		errs = append(errs, err)
+	}
+
+	require.Len(t, errs, 1)
+	require.ErrorIs(t, errs[0], ErrKeysetOrdered)
+}
@@ -0,0 +1,285 @@
+package postrgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.uber.org/dig"
+	"go.uber.org/zap"
+)
+
+const (
+	listenMinBackoff = time.Second
+	listenMaxBackoff = 30 * time.Second
+)
+
+type (
+	Notification struct {
+		Channel string
+		Payload string
+		// PID процесс постгри, который отправил NOTIFY
+		PID uint32
+	}
+
+	NotificationHandler func(ctx context.Context, n Notification) error
+
+	// Subscription подписка на каналы pgx соединения DB из конфига, отдаётся в группу `pg_subscriptions`
+	Subscription struct {
+		DB       string
+		Channels []string
+		Handler  NotificationHandler
+		// OnReconnect вызывается после переподключения, пока соединения не было, уведомления терялись,
+		// например, тут можно сбросить кэш целиком
+		OnReconnect func(ctx context.Context) error
+	}
+
+	SubscriberParams struct {
+		dig.In
+
+		Subscriptions []Subscription `group:"pg_subscriptions"`
+		PgxPoolDB     map[string]*pgxpool.Pool
+		Logger        *zap.Logger
+	}
+
+	// Subscriber держит по отдельному соединению на базу, слушает каналы и раздаёт уведомления обработчикам.
+	// Обработчики вызываются по очереди в порядке прихода уведомлений.
+	Subscriber struct {
+		pools  map[string]*pgxpool.Pool
+		subs   map[string][]Subscription
+		logger *zap.Logger
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+		// connect отдаёт соединение для LISTEN, по умолчанию acquire, в тестах подменяется
+		connect    func(ctx context.Context, db string) (listenConn, error)
+		minBackoff time.Duration
+		maxBackoff time.Duration
+	}
+
+	// listenConn часть *pgx.Conn, которая нужна подписке
+	listenConn interface {
+		Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+		WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+		Close(ctx context.Context) error
+	}
+)
+
+var (
+	ErrEmptyChannels = errors.New("subscription without channels")
+)
+
+// JSONHandler раскладывает json payload в T перед вызовом f
+func JSONHandler[T any](f func(ctx context.Context, channel string, payload T) error) NotificationHandler {
+	return func(ctx context.Context, n Notification) error {
+		var payload T
+		if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			return fmt.Errorf("unmarshal %s payload: %w", n.Channel, err)
+		}
+
+		return f(ctx, n.Channel, payload)
+	}
+}
+
+func NewSubscriber(p SubscriberParams) (*Subscriber, error) {
+	if len(p.Subscriptions) == 0 {
+		return nil, nil
+	}
+
+	s := &Subscriber{
+		pools:      make(map[string]*pgxpool.Pool),
+		subs:       make(map[string][]Subscription),
+		logger:     p.Logger.Named("pg_subscriber"),
+		minBackoff: listenMinBackoff,
+		maxBackoff: listenMaxBackoff,
+	}
+
+	s.connect = s.acquire
+
+	for _, sub := range p.Subscriptions {
+		pool, ok := p.PgxPoolDB[sub.DB]
+		if !ok {
+			return nil, fmt.Errorf("subscription to %s: %w", sub.DB, ErrUnknownConn)
+		}
+
+		if len(sub.Channels) == 0 {
+			return nil, fmt.Errorf("subscription to %s: %w", sub.DB, ErrEmptyChannels)
+		}
+
+		s.pools[sub.DB] = pool
+		s.subs[sub.DB] = append(s.subs[sub.DB], sub)
+	}
+
+	return s, nil
+}
+
+func (s *Subscriber) Start(ctx context.Context) error {
+	ctx, s.cancel = context.WithCancel(ctx)
+
+	for db := range s.subs {
+		s.wg.Add(1)
+
+		go func(db string) {
+			defer s.wg.Done()
+			s.listen(ctx, db)
+		}(db)
+	}
+
+	return nil
+}
+
+// Stop прерывает ожидание уведомлений и ждёт, пока закончат работающие обработчики
+func (s *Subscriber) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// listen держит соединение и переподключается, пока сервис не остановится. После живой сессии пауза
+// минимальная, а растёт только на неудачных попытках подряд.
+func (s *Subscriber) listen(ctx context.Context, db string) {
+	logger := s.logger.With(zap.String("db", db))
+	backoff := s.minBackoff
+	reconnect := false
+
+	for {
+		listened, err := s.session(ctx, db, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if listened {
+			backoff = s.minBackoff
+			reconnect = true
+		}
+
+		logger.Warn("listen connection lost, reconnecting", zap.Duration("backoff", backoff), zap.Error(err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !listened {
+			backoff = min(backoff*2, s.maxBackoff)
+		}
+	}
+}
+
+// acquire забирает соединение из пула насовсем, чтобы LISTEN не достался чужим запросам
+func (s *Subscriber) acquire(ctx context.Context, db string) (listenConn, error) {
+	poolConn, err := s.pools[db].Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire conn: %w", err)
+	}
+
+	return poolConn.Hijack(), nil
+}
+
+// session возвращает true, если успели подписаться, тогда следующая попытка считается переподключением
+func (s *Subscriber) session(ctx context.Context, db string, reconnect bool) (bool, error) {
+	conn, err := s.connect(ctx, db)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_ = conn.Close(closeCtx)
+	}()
+
+	handlers := make(map[string][]NotificationHandler)
+
+	for _, sub := range s.subs[db] {
+		for _, channel := range sub.Channels {
+			if _, ok := handlers[channel]; !ok {
+				if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+					return false, fmt.Errorf("listen %s: %w", channel, err)
+				}
+			}
+
+			handlers[channel] = append(handlers[channel], sub.Handler)
+		}
+	}
+
+	s.logger.Info("listening", zap.String("db", db), zap.Int("channels", len(handlers)))
+
+	// обработчики доделывают начатое и при остановке сервиса
+	handlerCtx := context.WithoutCancel(ctx)
+
+	if reconnect {
+		for _, sub := range s.subs[db] {
+			if sub.OnReconnect == nil {
+				continue
+			}
+
+			if err = sub.OnReconnect(handlerCtx); err != nil {
+				s.logger.Error("on reconnect", zap.String("db", db), zap.Error(err))
+			}
+		}
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+
+		notification := Notification{
+			Channel: n.Channel,
+			Payload: n.Payload,
+			PID:     n.PID,
+		}
+
+		for _, handler := range handlers[n.Channel] {
+			s.handle(handlerCtx, db, handler, notification)
+		}
+	}
+}
+
+func (s *Subscriber) handle(ctx context.Context, db string, handler NotificationHandler, n Notification) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error(
+				"notification handler panic",
+				zap.String("db", db),
+				zap.String("channel", n.Channel),
+				zap.Error(fmt.Errorf("recover panic %v", r)),
+			)
+		}
+	}()
+
+	if err := handler(ctx, n); err != nil {
+		s.logger.Error(
+			"notification handler",
+			zap.String("db", db),
+			zap.String("channel", n.Channel),
+			zap.Error(err),
+		)
+	}
+}
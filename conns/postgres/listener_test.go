@@ -0,0 +1,168 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type fakeListenConn struct {
+	notifications []*pgconn.Notification
+	// err после уведомлений, без него соединение живёт до отмены контекста
+	err    error
+	listen []string
+}
+
+func TestJSONHandler(t *testing.T) {
+	type invalidation struct {
+		Key string `json:"key"`
+	}
+
+	var got invalidation
+
+	handler := JSONHandler(func(_ context.Context, channel string, payload invalidation) error {
+		require.Equal(t, "cache", channel)
+		got = payload
+		return nil
+	})
+
+	require.NoError(t, handler(context.Background(), Notification{Channel: "cache", Payload: `{"key":"user:1"}`}))
+	require.Equal(t, "user:1", got.Key)
+	require.Error(t, handler(context.Background(), Notification{Channel: "cache", Payload: "user:1"}))
+}
+
+func TestNewSubscriber(t *testing.T) {
+	s, err := NewSubscriber(SubscriberParams{Logger: zap.NewNop()})
+	require.NoError(t, err)
+	require.Nil(t, s)
+
+	_, err = NewSubscriber(SubscriberParams{
+		Subscriptions: []Subscription{{DB: "master", Channels: []string{"cache"}}},
+		PgxPoolDB:     map[string]*pgxpool.Pool{},
+		Logger:        zap.NewNop(),
+	})
+	require.ErrorIs(t, err, ErrUnknownConn)
+}
+
+func TestSubscriberReconnect(t *testing.T) {
+	var (
+		errLost     = errors.New("connection lost")
+		errRefused  = errors.New("connection refused")
+		received    = make(chan string, 10)
+		reconnected = make(chan struct{}, 10)
+	)
+
+	// живая сессия, две неудачные попытки, снова живая сессия
+	sessions := []struct {
+		conn *fakeListenConn
+		err  error
+	}{
+		{conn: &fakeListenConn{
+			notifications: []*pgconn.Notification{{Channel: "cache", Payload: "first"}},
+			err:           errLost,
+		}},
+		{err: errRefused},
+		{err: errRefused},
+		{conn: &fakeListenConn{notifications: []*pgconn.Notification{{Channel: "cache", Payload: "second"}}}},
+	}
+
+	first := sessions[0].conn
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	s := &Subscriber{
+		subs: map[string][]Subscription{"master": {{
+			DB:       "master",
+			Channels: []string{"cache"},
+			Handler: func(_ context.Context, n Notification) error {
+				received <- n.Payload
+				return nil
+			},
+			OnReconnect: func(context.Context) error {
+				reconnected <- struct{}{}
+				return nil
+			},
+		}}},
+		logger:     zap.New(core),
+		minBackoff: time.Millisecond,
+		maxBackoff: time.Second,
+		connect: func(context.Context, string) (listenConn, error) {
+			session := sessions[0]
+			sessions = sessions[1:]
+
+			if session.err != nil {
+				return nil, session.err
+			}
+
+			return session.conn, nil
+		},
+	}
+
+	require.NoError(t, s.Start(context.Background()))
+
+	require.Equal(t, "first", waitFor(t, received))
+	waitFor(t, reconnected)
+	require.Equal(t, "second", waitFor(t, received))
+
+	require.NoError(t, s.Stop(context.Background()))
+
+	require.Equal(t, []string{`LISTEN "cache"`}, first.listen)
+
+	// OnReconnect только после переподключения, неудачные попытки его не вызывают
+	require.Empty(t, reconnected)
+
+	// после живой сессии пауза минимальная, удваивается только на неудачных попытках
+	backoffs := make([]any, 0, 3)
+	for _, e := range logs.FilterMessage("listen connection lost, reconnecting").AllUntimed() {
+		backoffs = append(backoffs, e.ContextMap()["backoff"])
+	}
+
+	require.Equal(t, []any{time.Millisecond, time.Millisecond, 2 * time.Millisecond}, backoffs)
+}
+
+func waitFor[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+
+		var v T
+		return v
+	}
+}
+
+func (c *fakeListenConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.listen = append(c.listen, sql)
+	return nil, nil
+}
+
+func (c *fakeListenConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	if len(c.notifications) != 0 {
+		n := c.notifications[0]
+		c.notifications = c.notifications[1:]
+
+		return n, nil
+	}
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	<-ctx.Done()
+
+	return nil, ctx.Err()
+}
+
+func (c *fakeListenConn) Close(context.Context) error {
+	return nil
+}
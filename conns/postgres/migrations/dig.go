@@ -0,0 +1,9 @@
+package migrations
+
+import (
+	"github.com/nenormalka/freya/types"
+)
+
+var Module = types.Module{
+	{CreateFunc: NewMigrator},
+}
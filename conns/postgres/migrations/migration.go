@@ -0,0 +1,150 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	upSuffix   = ".up.sql"
+	downSuffix = ".down.sql"
+)
+
+type (
+	// Source миграции одной базы. FS обычно embed.FS, файлы вида 0001_create_users.up.sql и
+	// 0001_create_users.down.sql, down необязателен.
+	Source struct {
+		// DB имя соединения из config.DB
+		DB string
+		FS fs.FS
+		// Dir директория внутри FS, по дефолту корень
+		Dir string
+	}
+
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+)
+
+var (
+	ErrNoDown = errors.New("migration has no down script")
+)
+
+// parseSource читает миграции из FS, отсортированные по версии
+func parseSource(src Source) ([]Migration, error) {
+	dir := src.Dir
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := fs.ReadDir(src.FS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir %s: %w", dir, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		fileName := entry.Name()
+
+		var (
+			base string
+			up   bool
+		)
+
+		switch {
+		case strings.HasSuffix(fileName, upSuffix):
+			base, up = strings.TrimSuffix(fileName, upSuffix), true
+		case strings.HasSuffix(fileName, downSuffix):
+			base = strings.TrimSuffix(fileName, downSuffix)
+		default:
+			continue
+		}
+
+		version, name, err := parseName(base)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", fileName, err)
+		}
+
+		body, err := fs.ReadFile(src.FS, path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %s: version %d already used by %s", fileName, version, m.Name)
+		}
+
+		if up {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func parseName(base string) (int64, string, error) {
+	versionStr, name, _ := strings.Cut(base, "_")
+
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", fmt.Errorf("bad version %q", versionStr)
+	}
+
+	return version, name, nil
+}
+
+// mergeMigrations склеивает миграции нескольких источников одной базы
+func mergeMigrations(a, b []Migration) ([]Migration, error) {
+	seen := make(map[int64]struct{}, len(a))
+	for _, m := range a {
+		seen[m.Version] = struct{}{}
+	}
+
+	for _, m := range b {
+		if _, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d", m.Version)
+		}
+
+		a = append(a, m)
+	}
+
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Version < a[j].Version
+	})
+
+	return a, nil
+}
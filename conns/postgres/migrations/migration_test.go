@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSource(t *testing.T) {
+	migrations, err := parseSource(Source{
+		DB:  "master",
+		Dir: "sql",
+		FS: fstest.MapFS{
+			"sql/0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+			"sql/0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT);")},
+			"sql/0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+			"sql/README.md":                  {Data: []byte("skip me")},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Migration{
+		{Version: 1, Name: "create_users", Up: "CREATE TABLE users (id BIGINT);", Down: "DROP TABLE users;"},
+		{Version: 2, Name: "add_email", Up: "ALTER TABLE users ADD COLUMN email TEXT;"},
+	}, migrations)
+
+	_, err = parseSource(Source{FS: fstest.MapFS{
+		"0001_create_users.up.sql":  {Data: []byte("CREATE TABLE users (id BIGINT);")},
+		"0001_create_orders.up.sql": {Data: []byte("CREATE TABLE orders (id BIGINT);")},
+	}})
+	require.Error(t, err)
+
+	_, err = parseSource(Source{FS: fstest.MapFS{
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	}})
+	require.Error(t, err)
+
+	_, err = mergeMigrations(migrations, []Migration{{Version: 2, Name: "other", Up: "SELECT 1;"}})
+	require.Error(t, err)
+}
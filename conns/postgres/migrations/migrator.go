@@ -0,0 +1,368 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/nenormalka/freya/config"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/dig"
+	"go.uber.org/zap"
+)
+
+const (
+	undefinedTableCode = "42P01"
+)
+
+type (
+	Params struct {
+		dig.In
+
+		Config     *config.Config
+		Logger     *zap.Logger
+		SqlxPoolDB map[string]*sqlx.DB
+		PgxPoolDB  map[string]*pgxpool.Pool
+		Sources    []Source `group:"migrations"`
+	}
+
+	// Migrator накатывает и откатывает миграции баз под advisory lock, так что при нескольких
+	// репликах сервиса мигрирует только одна, остальные ждут и видят уже применённые версии.
+	Migrator struct {
+		cfg        config.MigrationsConfig
+		logger     *zap.Logger
+		sqlxPoolDB map[string]*sqlx.DB
+		pgxPoolDB  map[string]*pgxpool.Pool
+		migrations map[string][]Migration
+		lockKey    int64
+		dryRun     bool
+	}
+
+	Status struct {
+		Version   int64
+		Name      string
+		Applied   bool
+		AppliedAt time.Time
+	}
+)
+
+var (
+	ErrUnknownDB = errors.New("unknown db")
+
+	tableRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+)
+
+func NewMigrator(p Params) (*Migrator, error) {
+	if !tableRe.MatchString(p.Config.Migrations.Table) {
+		return nil, fmt.Errorf("bad migrations table name %q", p.Config.Migrations.Table)
+	}
+
+	m := &Migrator{
+		cfg:        p.Config.Migrations,
+		logger:     p.Logger.Named("migrations"),
+		sqlxPoolDB: p.SqlxPoolDB,
+		pgxPoolDB:  p.PgxPoolDB,
+		migrations: make(map[string][]Migration, len(p.Sources)),
+		lockKey:    lockKey(p.Config.Migrations.Table),
+	}
+
+	for _, src := range p.Sources {
+		_, isSqlx := p.SqlxPoolDB[src.DB]
+		_, isPgx := p.PgxPoolDB[src.DB]
+
+		if !isSqlx && !isPgx {
+			return nil, fmt.Errorf("migrations for %s: %w", src.DB, ErrUnknownDB)
+		}
+
+		parsed, err := parseSource(src)
+		if err != nil {
+			return nil, fmt.Errorf("migrations for %s: %w", src.DB, err)
+		}
+
+		if m.migrations[src.DB], err = mergeMigrations(m.migrations[src.DB], parsed); err != nil {
+			return nil, fmt.Errorf("migrations for %s: %w", src.DB, err)
+		}
+	}
+
+	return m, nil
+}
+
+// DryRun возвращает копию мигратора, который ничего не меняет в базе, а только отдаёт то, что было бы сделано
+func (m *Migrator) DryRun() *Migrator {
+	c := *m
+	c.dryRun = true
+
+	return &c
+}
+
+// DBs имена баз, для которых есть миграции
+func (m *Migrator) DBs() []string {
+	dbs := make([]string, 0, len(m.migrations))
+	for db := range m.migrations {
+		dbs = append(dbs, db)
+	}
+
+	sort.Strings(dbs)
+
+	return dbs
+}
+
+// Startup вызывается движком до запуска сервисов, накатывает миграции всех баз, если это не выключено
+func (m *Migrator) Startup(ctx context.Context) error {
+	if !m.cfg.Auto {
+		return nil
+	}
+
+	migrator := m
+	if m.cfg.DryRun {
+		migrator = m.DryRun()
+	}
+
+	for _, db := range m.DBs() {
+		if _, err := migrator.Up(ctx, db); err != nil {
+			return fmt.Errorf("migrate %s: %w", db, err)
+		}
+	}
+
+	return nil
+}
+
+// Up применяет все ещё не применённые миграции по возрастанию версии
+func (m *Migrator) Up(ctx context.Context, db string) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withSession(ctx, db, func(s session) error {
+		appliedVersions, err := m.applied(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations[db] {
+			if _, ok := appliedVersions[migration.Version]; ok {
+				continue
+			}
+
+			if err = m.apply(ctx, s, db, migration, migration.Up, true); err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций
+func (m *Migrator) Down(ctx context.Context, db string, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withSession(ctx, db, func(s session) error {
+		appliedVersions, err := m.applied(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		migrations := m.migrations[db]
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := appliedVersions[migration.Version]; !ok {
+				continue
+			}
+
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrNoDown)
+			}
+
+			if err = m.apply(ctx, s, db, migration, migration.Down, false); err != nil {
+				return err
+			}
+
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status все известные миграции базы, в том числе применённые, файлов которых уже нет
+func (m *Migrator) Status(ctx context.Context, db string) ([]Status, error) {
+	s, err := m.session(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	appliedVersions, err := m.applied(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations[db]))
+
+	for _, migration := range m.migrations[db] {
+		a, ok := appliedVersions[migration.Version]
+		delete(appliedVersions, migration.Version)
+
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: a.AppliedAt,
+		})
+	}
+
+	for _, a := range appliedVersions {
+		statuses = append(statuses, Status{
+			Version:   a.Version,
+			Name:      a.Name,
+			Applied:   true,
+			AppliedAt: a.AppliedAt,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, s session, db string, migration Migration, script string, up bool) error {
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+
+	logger := m.logger.With(
+		zap.String("db", db),
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.String("direction", direction),
+	)
+
+	if m.dryRun {
+		logger.Info("migration pending (dry run)")
+		return nil
+	}
+
+	start := time.Now()
+
+	err := s.inTx(ctx, func(exec execFunc) error {
+		if err := exec(ctx, script); err != nil {
+			return err
+		}
+
+		if up {
+			return exec(ctx, "INSERT INTO "+m.cfg.Table+" (version, name) VALUES ($1, $2)", migration.Version, migration.Name)
+		}
+
+		return exec(ctx, "DELETE FROM "+m.cfg.Table+" WHERE version = $1", migration.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s: %w", migration.Version, migration.Name, direction, err)
+	}
+
+	logger.Info("migration applied", zap.Duration("duration", time.Since(start)))
+
+	return nil
+}
+
+// withSession берёт соединение, advisory lock и создаёт таблицу версий. В dry run блокировка не берётся.
+func (m *Migrator) withSession(ctx context.Context, db string, f func(s session) error) error {
+	s, err := m.session(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	if m.dryRun {
+		return f(s)
+	}
+
+	if err = s.exec(ctx, "SELECT pg_advisory_lock($1)", m.lockKey); err != nil {
+		return fmt.Errorf("advisory lock: %w", err)
+	}
+
+	defer func() {
+		// контекст уже может быть отменён, а блокировку надо отпустить, иначе она останется на соединении в пуле
+		unlockCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+
+		if err := s.exec(unlockCtx, "SELECT pg_advisory_unlock($1)", m.lockKey); err != nil {
+			m.logger.Error("advisory unlock", zap.String("db", db), zap.Error(err))
+		}
+	}()
+
+	if err = s.exec(ctx, `CREATE TABLE IF NOT EXISTS `+m.cfg.Table+` (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	return f(s)
+}
+
+func (m *Migrator) session(ctx context.Context, db string) (session, error) {
+	if pool, ok := m.sqlxPoolDB[db]; ok {
+		conn, err := pool.Conn(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get conn %s: %w", db, err)
+		}
+
+		return &sqlSession{conn: conn}, nil
+	}
+
+	if pool, ok := m.pgxPoolDB[db]; ok {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("acquire conn %s: %w", db, err)
+		}
+
+		return &pgxSession{conn: conn}, nil
+	}
+
+	return nil, fmt.Errorf("%s: %w", db, ErrUnknownDB)
+}
+
+// applied применённые версии, отсутствие таблицы значит, что ещё ничего не применялось
+func (m *Migrator) applied(ctx context.Context, s session) (map[int64]applied, error) {
+	rows, err := s.applied(ctx, "SELECT version, name, applied_at FROM "+m.cfg.Table)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == undefinedTableCode {
+			return map[int64]applied{}, nil
+		}
+
+		return nil, fmt.Errorf("select applied migrations: %w", err)
+	}
+
+	res := make(map[int64]applied, len(rows))
+	for _, a := range rows {
+		res[a.Version] = a
+	}
+
+	return res, nil
+}
+
+// lockKey ключ advisory lock'а зависит от таблицы, чтобы сервисы с разными таблицами в одной базе не мешали друг другу
+func lockKey(table string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte("freya.migrations." + table))
+
+	return int64(h.Sum64())
+}
@@ -0,0 +1,219 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/nenormalka/freya/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testTable = "schema_migrations"
+)
+
+func TestMigratorUp(t *testing.T) {
+	m, mock := newTestMigrator(t, false, fstest.MapFS{
+		"0001_create_users.up.sql":  {Data: []byte("CREATE TABLE users (id BIGINT);")},
+		"0002_add_email.up.sql":     {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+		"0003_create_orders.up.sql": {Data: []byte("CREATE TABLE orders (id BIGINT);")},
+	})
+
+	// блокировка, таблица версий, по транзакции на миграцию, разблокировка
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(m.lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS ` + testTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectApplied(mock, 1)
+
+	for _, migration := range []struct {
+		version int64
+		name    string
+		script  string
+	}{
+		{version: 2, name: "add_email", script: `ALTER TABLE users ADD COLUMN email TEXT;`},
+		{version: 3, name: "create_orders", script: `CREATE TABLE orders \(id BIGINT\);`},
+	} {
+		mock.ExpectBegin()
+		mock.ExpectExec(migration.script).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO `+testTable).
+			WithArgs(migration.version, migration.name).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(m.lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done, err := m.Up(context.Background(), "master")
+	require.NoError(t, err)
+	require.Equal(t, []int64{2, 3}, versions(done))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorUpFailed(t *testing.T) {
+	m, mock := newTestMigrator(t, false, fstest.MapFS{
+		"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id BIGINT);")},
+	})
+
+	errSyntax := errors.New("syntax error")
+
+	// упавшая миграция откатывает свою транзакцию, а блокировка всё равно отпускается
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS ` + testTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectApplied(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE users`).WillReturnError(errSyntax)
+	mock.ExpectRollback()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done, err := m.Up(context.Background(), "master")
+	require.ErrorIs(t, err, errSyntax)
+	require.Empty(t, done)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDown(t *testing.T) {
+	m, mock := newTestMigrator(t, false, fstest.MapFS{
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+		"0002_add_email.down.sql":    {Data: []byte("ALTER TABLE users DROP COLUMN email;")},
+	})
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS ` + testTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectApplied(mock, 1, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE users DROP COLUMN email;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM ` + testTable).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done, err := m.Down(context.Background(), "master", 1)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, versions(done))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDownWithoutScript(t *testing.T) {
+	m, mock := newTestMigrator(t, false, fstest.MapFS{
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id BIGINT);")},
+		"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"0002_add_email.up.sql":      {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+	})
+
+	// без down скрипта ничего не откатывается, транзакция не открывается
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS ` + testTable).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectApplied(mock, 1, 2)
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	done, err := m.Down(context.Background(), "master", 2)
+	require.ErrorIs(t, err, ErrNoDown)
+	require.Empty(t, done)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorStatus(t *testing.T) {
+	m, mock := newTestMigrator(t, false, fstest.MapFS{
+		"0002_add_email.up.sql":     {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+		"0003_create_orders.up.sql": {Data: []byte("CREATE TABLE orders (id BIGINT);")},
+	})
+
+	// статус только читает таблицу версий, без блокировки
+	expectApplied(mock, 1, 2)
+
+	statuses, err := m.Status(context.Background(), "master")
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	// применённая версия, файла которой уже нет
+	require.Equal(t, int64(1), statuses[0].Version)
+	require.True(t, statuses[0].Applied)
+	require.Equal(t, "add_email", statuses[1].Name)
+	require.True(t, statuses[1].Applied)
+	require.Equal(t, "create_orders", statuses[2].Name)
+	require.False(t, statuses[2].Applied)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDryRun(t *testing.T) {
+	m, mock := newTestMigrator(t, true, fstest.MapFS{
+		"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id BIGINT);")},
+		"0002_add_email.up.sql":    {Data: []byte("ALTER TABLE users ADD COLUMN email TEXT;")},
+	})
+
+	// в dry run нет ни блокировки, ни таблицы версий, ни самих миграций: таблицы ещё нет, всё в ожидании
+	mock.ExpectQuery(`SELECT version, name, applied_at FROM ` + testTable).
+		WillReturnError(&pgconn.PgError{Code: undefinedTableCode})
+
+	require.NoError(t, m.Startup(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	expectApplied(mock, 1)
+
+	done, err := m.DryRun().Up(context.Background(), "master")
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, versions(done))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorStartupDisabled(t *testing.T) {
+	m, mock := newTestMigrator(t, false, fstest.MapFS{
+		"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id BIGINT);")},
+	})
+	m.cfg.Auto = false
+
+	require.NoError(t, m.Startup(context.Background()))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newTestMigrator(t *testing.T, dryRun bool, fsys fstest.MapFS) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = mockDB.Close()
+	})
+
+	m, err := NewMigrator(Params{
+		Config: &config.Config{Migrations: config.MigrationsConfig{
+			Auto:   true,
+			DryRun: dryRun,
+			Table:  testTable,
+		}},
+		Logger:     zap.NewNop(),
+		SqlxPoolDB: map[string]*sqlx.DB{"master": sqlx.NewDb(mockDB, "sqlmock")},
+		Sources:    []Source{{DB: "master", FS: fsys}},
+	})
+	require.NoError(t, err)
+
+	return m, mock
+}
+
+func expectApplied(mock sqlmock.Sqlmock, versions ...int64) {
+	rows := sqlmock.NewRows([]string{"version", "name", "applied_at"})
+	for _, version := range versions {
+		name := map[int64]string{1: "create_users", 2: "add_email"}[version]
+		rows.AddRow(version, name, time.Now())
+	}
+
+	mock.ExpectQuery(`SELECT version, name, applied_at FROM ` + testTable).WillReturnRows(rows)
+}
+
+func versions(migrations []Migration) []int64 {
+	res := make([]int64, 0, len(migrations))
+	for _, migration := range migrations {
+		res = append(res, migration.Version)
+	}
+
+	return res
+}
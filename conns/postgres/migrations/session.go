@@ -0,0 +1,124 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type (
+	// session одно соединение из пула: advisory lock сессионный, поэтому все миграции идут через него
+	session interface {
+		exec(ctx context.Context, query string, args ...any) error
+		applied(ctx context.Context, query string) ([]applied, error)
+		inTx(ctx context.Context, f func(exec execFunc) error) error
+		close()
+	}
+
+	execFunc func(ctx context.Context, query string, args ...any) error
+
+	applied struct {
+		Version   int64
+		Name      string
+		AppliedAt time.Time
+	}
+
+	sqlSession struct {
+		conn *sql.Conn
+	}
+
+	pgxSession struct {
+		conn *pgxpool.Conn
+	}
+)
+
+func (s *sqlSession) exec(ctx context.Context, query string, args ...any) error {
+	_, err := s.conn.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (s *sqlSession) applied(ctx context.Context, query string) ([]applied, error) {
+	rows, err := s.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []applied
+
+	for rows.Next() {
+		var a applied
+		if err = rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+
+		res = append(res, a)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *sqlSession) inTx(ctx context.Context, f func(exec execFunc) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	err = f(func(ctx context.Context, query string, args ...any) error {
+		_, err := tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqlSession) close() {
+	_ = s.conn.Close()
+}
+
+func (s *pgxSession) exec(ctx context.Context, query string, args ...any) error {
+	_, err := s.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func (s *pgxSession) applied(ctx context.Context, query string) ([]applied, error) {
+	rows, err := s.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []applied
+
+	for rows.Next() {
+		var a applied
+		if err = rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+
+		res = append(res, a)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *pgxSession) inTx(ctx context.Context, f func(exec execFunc) error) error {
+	return s.conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		return f(func(ctx context.Context, query string, args ...any) error {
+			_, err := tx.Exec(ctx, query, args...)
+			return err
+		})
+	})
+}
+
+func (s *pgxSession) close() {
+	s.conn.Release()
+}
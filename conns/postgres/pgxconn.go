@@ -10,10 +10,10 @@ import (
 	"github.com/nenormalka/freya/conns/connectors"
 	"github.com/nenormalka/freya/conns/postgres/collector"
 	dbtypes "github.com/nenormalka/freya/conns/postgres/types"
+	"github.com/nenormalka/freya/conns/resilience"
 	"github.com/nenormalka/freya/types"
 
 	"github.com/georgysavva/scany/pgxscan"
-	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"go.uber.org/zap"
 )
@@ -21,6 +21,7 @@ import (
 type (
 	PGXPoolConn struct {
 		*pgxpool.Pool
+		name    string
 		logger  *zap.Logger
 		appName string
 	}
@@ -31,6 +32,7 @@ func NewPGXPoolConn(
 	pgxPool map[string]*pgxpool.Pool,
 	logger *zap.Logger,
 	cfg *config.Config,
+	policies *resilience.Policies,
 ) (map[string]connectors.DBConnector[dbtypes.PgxConn, dbtypes.PgxTx], error) {
 	if len(pgxPool) == 0 {
 		return nil, nil
@@ -41,6 +43,7 @@ func NewPGXPoolConn(
 	for i := range pgxPool {
 		conn := &PGXPoolConn{
 			Pool:    pgxPool[i],
+			name:    i,
 			logger:  logger,
 			appName: cfg.AppName,
 		}
@@ -51,7 +54,7 @@ func NewPGXPoolConn(
 
 		conn.ping(ctx)
 
-		pools[i] = conn
+		pools[i] = resilience.Wrap[dbtypes.PgxConn, dbtypes.PgxTx](conn, policies.Get(CheckName(ConnTypePGX, i)))
 	}
 
 	return pools, nil
@@ -62,8 +65,15 @@ func (c *PGXPoolConn) CallContext(
 	queryName string,
 	callFunc func(ctx context.Context, db dbtypes.PgxConn) error,
 ) error {
+	ctx = withMetricsService(ctx, c.appName)
+
+	var querier dbtypes.PgxQuerier = c
+	if active := activeTxFromContext(ctx, c.name); active != nil && active.pgxTx != nil {
+		querier = &dbtypes.PgxTx{PgxTransactor: active.pgxTx}
+	}
+
 	return types.WithSQLMetrics(queryName, c.appName, func() error {
-		return callFunc(ctx, dbtypes.PgxConn{PgxQuerier: c})
+		return callFunc(ctx, dbtypes.PgxConn{PgxQuerier: querier})
 	})
 }
 
@@ -71,23 +81,50 @@ func (c *PGXPoolConn) CallTransaction(
 	ctx context.Context,
 	txName string,
 	callFunc func(ctx context.Context, tx dbtypes.PgxTx) error,
+	opts ...connectors.TxOption,
 ) error {
+	ctx = withMetricsService(ctx, c.appName)
+
+	if active := activeTxFromContext(ctx, c.name); active != nil && active.pgxTx != nil {
+		return types.WithSQLMetrics(txName, c.appName, func() error {
+			return active.savepoint(ctx, c.name, func(ctx context.Context, nested *activeTx) error {
+				return callFunc(ctx, dbtypes.PgxTx{PgxTransactor: nested.pgxTx})
+			})
+		})
+	}
+
+	mode, retry, err := txSettings(ctx, opts)
+	if err != nil {
+		return err
+	}
+
 	return types.WithSQLMetrics(txName, c.appName, func() error {
-		tx, err := c.BeginTx(ctx, pgx.TxOptions{})
-		if err != nil {
-			return err
-		}
+		return withTxRetry(ctx, txName, retry, func() error {
+			return c.transaction(ctx, txName, mode, callFunc)
+		})
+	})
+}
 
-		if err = callFunc(ctx, dbtypes.PgxTx{PgxTransactor: tx}); err != nil {
-			if rErr := tx.Rollback(ctx); rErr != nil {
-				c.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
-			}
+func (c *PGXPoolConn) transaction(
+	ctx context.Context,
+	txName string,
+	opts TxOptions,
+	callFunc func(ctx context.Context, tx dbtypes.PgxTx) error,
+) error {
+	tx, err := c.BeginTx(ctx, opts.pgxOptions())
+	if err != nil {
+		return err
+	}
 
-			return err
+	if err = callFunc(ctx, dbtypes.PgxTx{PgxTransactor: tx}); err != nil {
+		if rErr := tx.Rollback(ctx); rErr != nil {
+			c.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
 		}
 
-		return tx.Commit(ctx)
-	})
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (c *PGXPoolConn) Select(ctx context.Context, dst any, query string, args ...any) error {
@@ -13,6 +13,9 @@ import (
 
 const (
 	driverName = "pgx"
+
+	ConnTypeSQLX = "sqlx"
+	ConnTypePGX  = "pgx"
 )
 
 func NewPostgres(config PostgresConfig) (map[string]*sqlx.DB, error) {
@@ -69,6 +72,12 @@ func newDb(cfg DBConfig) (*sqlx.DB, error) {
 	return sqlx.NewDb(db, driverName), nil
 }
 
+// CheckName имя health проверки и resilience политики базы: db.<тип>.<имя>. Тип в имени нужен,
+// так как sqlx и pgx соединения могут называться одинаково.
+func CheckName(connType, nameConn string) string {
+	return "db." + connType + "." + nameConn
+}
+
 func newConns[T any](poolDB map[string]*sqlx.DB, f func(nameConn string) T) map[string]T {
 	if len(poolDB) == 0 {
 		return nil
@@ -0,0 +1,362 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nenormalka/freya/conns/connectors"
+	dbtypes "github.com/nenormalka/freya/conns/postgres/types"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jmoiron/sqlx"
+	lilith "github.com/nenormalka/lilith/patterns"
+	"go.uber.org/zap"
+)
+
+const (
+	BalancerRoundRobin Balancer = "round_robin"
+	BalancerLeastBusy  Balancer = "least_busy"
+)
+
+type (
+	Balancer string
+
+	// RoutedConn отправляет транзакции в primary, а CallContext раскидывает по живым репликам.
+	// Без живых реплик и с ForcePrimary в контексте запрос уходит в primary.
+	RoutedConn[T connectors.ConnectDB, M connectors.ConnectTx] struct {
+		name        string
+		primaryName string
+		primary     connectors.DBConnector[T, M]
+		replicas    []*replica[T, M]
+		balancer    Balancer
+		next        atomic.Uint64
+	}
+
+	replica[T connectors.ConnectDB, M connectors.ConnectTx] struct {
+		name     string
+		conn     connectors.DBConnector[T, M]
+		ping     func(ctx context.Context) error
+		healthy  atomic.Bool
+		inFlight atomic.Int64
+	}
+
+	// Routes маршрутизирующие коннекты из db_routes. Это Runnable, который пингует реплики и выводит
+	// упавшие из ротации.
+	Routes struct {
+		logger *zap.Logger
+		sql    map[string]*RoutedConn[*sqlx.DB, *sqlx.Tx]
+		goqu   map[string]*RoutedConn[*goqu.Database, *goqu.TxDatabase]
+		pgx    map[string]*RoutedConn[dbtypes.PgxConn, dbtypes.PgxTx]
+		checks []routeCheck
+		cancel context.CancelFunc
+	}
+
+	routeCheck struct {
+		interval time.Duration
+		check    func(ctx context.Context)
+	}
+
+	forcePrimaryKey struct{}
+)
+
+// ForcePrimary отправляет все вызовы с этим контекстом в primary, например чтобы прочитать только что записанное
+func ForcePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+func isForcePrimary(ctx context.Context) bool {
+	force, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return force
+}
+
+func NewRoutes(
+	config PostgresConfig,
+	logger *zap.Logger,
+	sqlxPoolDB map[string]*sqlx.DB,
+	pgxPoolDB map[string]*pgxpool.Pool,
+	sqlConns map[string]connectors.DBConnector[*sqlx.DB, *sqlx.Tx],
+	goquConns map[string]connectors.DBConnector[*goqu.Database, *goqu.TxDatabase],
+	pgxConns map[string]connectors.DBConnector[dbtypes.PgxConn, dbtypes.PgxTx],
+) (*Routes, error) {
+	if len(config.Routes) == 0 {
+		return nil, nil
+	}
+
+	routes := &Routes{
+		logger: logger,
+		sql:    make(map[string]*RoutedConn[*sqlx.DB, *sqlx.Tx]),
+		goqu:   make(map[string]*RoutedConn[*goqu.Database, *goqu.TxDatabase]),
+		pgx:    make(map[string]*RoutedConn[dbtypes.PgxConn, dbtypes.PgxTx]),
+	}
+
+	for _, cfg := range config.Routes {
+		if cfg.Balancer != BalancerRoundRobin && cfg.Balancer != BalancerLeastBusy {
+			return nil, fmt.Errorf("db route %s: unknown balancer %s", cfg.Name, cfg.Balancer)
+		}
+
+		if _, ok := sqlxPoolDB[cfg.Primary]; ok {
+			pings := make(map[string]func(ctx context.Context) error, len(sqlxPoolDB))
+			for name, db := range sqlxPoolDB {
+				pings[name] = db.PingContext
+			}
+
+			sqlConn, err := newRoutedConn(cfg, sqlConns, pings)
+			if err != nil {
+				return nil, err
+			}
+
+			goquConn, err := newRoutedConn(cfg, goquConns, pings)
+			if err != nil {
+				return nil, err
+			}
+
+			routes.sql[cfg.Name] = sqlConn
+			routes.goqu[cfg.Name] = goquConn
+			// sqlx и goqu ходят в одни и те же пулы, пингуем один раз и обновляем оба
+			routes.addCheck(cfg, func(ctx context.Context) {
+				sqlConn.check(ctx, logger)
+				goquConn.copyHealth(sqlConn)
+			})
+
+			continue
+		}
+
+		if _, ok := pgxPoolDB[cfg.Primary]; ok {
+			pings := make(map[string]func(ctx context.Context) error, len(pgxPoolDB))
+			for name, pool := range pgxPoolDB {
+				pings[name] = pool.Ping
+			}
+
+			pgxConn, err := newRoutedConn(cfg, pgxConns, pings)
+			if err != nil {
+				return nil, err
+			}
+
+			routes.pgx[cfg.Name] = pgxConn
+			routes.addCheck(cfg, func(ctx context.Context) {
+				pgxConn.check(ctx, logger)
+			})
+
+			continue
+		}
+
+		return nil, fmt.Errorf("db route %s: primary %s not found", cfg.Name, cfg.Primary)
+	}
+
+	return routes, nil
+}
+
+func newRoutedConn[T connectors.ConnectDB, M connectors.ConnectTx](
+	cfg RouteConfig,
+	conns map[string]connectors.DBConnector[T, M],
+	pings map[string]func(ctx context.Context) error,
+) (*RoutedConn[T, M], error) {
+	primary, ok := conns[cfg.Primary]
+	if !ok {
+		return nil, fmt.Errorf("db route %s: primary %s not found", cfg.Name, cfg.Primary)
+	}
+
+	rc := &RoutedConn[T, M]{
+		name:        cfg.Name,
+		primaryName: cfg.Primary,
+		primary:     primary,
+		replicas:    make([]*replica[T, M], 0, len(cfg.Replicas)),
+		balancer:    cfg.Balancer,
+	}
+
+	for _, name := range cfg.Replicas {
+		conn, ok := conns[name]
+		if !ok {
+			return nil, fmt.Errorf("db route %s: replica %s not found or has another type", cfg.Name, name)
+		}
+
+		r := &replica[T, M]{
+			name: name,
+			conn: conn,
+			ping: pings[name],
+		}
+		r.healthy.Store(true)
+
+		rc.replicas = append(rc.replicas, r)
+	}
+
+	return rc, nil
+}
+
+func (rc *RoutedConn[T, M]) CallContext(
+	ctx context.Context,
+	queryName string,
+	callFunc func(ctx context.Context, db T) error,
+) error {
+	r := rc.pick(ctx)
+	if r == nil {
+		return rc.primary.CallContext(ctx, queryName, callFunc)
+	}
+
+	r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+
+	return r.conn.CallContext(ctx, queryName, callFunc)
+}
+
+func (rc *RoutedConn[T, M]) CallTransaction(
+	ctx context.Context,
+	txName string,
+	callFunc func(ctx context.Context, tx M) error,
+	opts ...connectors.TxOption,
+) error {
+	return rc.primary.CallTransaction(ctx, txName, callFunc, opts...)
+}
+
+// Primary возвращает коннект к primary, например для запросов, которые нельзя отправлять в реплику
+func (rc *RoutedConn[T, M]) Primary() connectors.DBConnector[T, M] {
+	return rc.primary
+}
+
+func (rc *RoutedConn[T, M]) pick(ctx context.Context) *replica[T, M] {
+	// внутри unit of work на primary реплика не увидит ещё не закоммиченное
+	if len(rc.replicas) == 0 || isForcePrimary(ctx) || InUnitOfWork(ctx, rc.primaryName) {
+		return nil
+	}
+
+	start := int(rc.next.Add(1) % uint64(len(rc.replicas)))
+
+	var picked *replica[T, M]
+
+	for i := range rc.replicas {
+		r := rc.replicas[(start+i)%len(rc.replicas)]
+		if !r.healthy.Load() {
+			continue
+		}
+
+		if rc.balancer == BalancerRoundRobin {
+			return r
+		}
+
+		if picked == nil || r.inFlight.Load() < picked.inFlight.Load() {
+			picked = r
+		}
+	}
+
+	return picked
+}
+
+func (rc *RoutedConn[T, M]) check(ctx context.Context, logger *zap.Logger) {
+	var wg sync.WaitGroup
+
+	for _, r := range rc.replicas {
+		if r.ping == nil {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(r *replica[T, M]) {
+			defer wg.Done()
+
+			err := r.ping(ctx)
+			// сервис останавливается, о реплике ничего не узнали
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return
+			}
+
+			healthy := err == nil
+			if r.healthy.Swap(healthy) == healthy {
+				return
+			}
+
+			if healthy {
+				logger.Info("db replica is back in rotation", zap.String("route", rc.name), zap.String("replica", r.name))
+				return
+			}
+
+			logger.Warn(
+				"db replica is out of rotation",
+				zap.String("route", rc.name),
+				zap.String("replica", r.name),
+				zap.Error(err),
+			)
+		}(r)
+	}
+
+	wg.Wait()
+}
+
+func (rc *RoutedConn[T, M]) copyHealth(from interface{ healthByName() map[string]bool }) {
+	health := from.healthByName()
+	for _, r := range rc.replicas {
+		r.healthy.Store(health[r.name])
+	}
+}
+
+func (rc *RoutedConn[T, M]) healthByName() map[string]bool {
+	health := make(map[string]bool, len(rc.replicas))
+	for _, r := range rc.replicas {
+		health[r.name] = r.healthy.Load()
+	}
+
+	return health
+}
+
+func (r *Routes) addCheck(cfg RouteConfig, check func(ctx context.Context)) {
+	r.checks = append(r.checks, routeCheck{
+		interval: cfg.CheckInterval,
+		check:    check,
+	})
+}
+
+func (r *Routes) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, c := range r.checks {
+		c := c
+		lilith.TickerV2(ctx, c.interval, func() {
+			checkCtx, cancel := context.WithTimeout(ctx, c.interval)
+			defer cancel()
+
+			c.check(checkCtx)
+		})
+	}
+
+	return nil
+}
+
+func (r *Routes) Stop(_ context.Context) error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	return nil
+}
+
+func (r *Routes) SQL(name string) (*RoutedConn[*sqlx.DB, *sqlx.Tx], bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	rc, ok := r.sql[name]
+	return rc, ok
+}
+
+func (r *Routes) GoQu(name string) (*RoutedConn[*goqu.Database, *goqu.TxDatabase], bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	rc, ok := r.goqu[name]
+	return rc, ok
+}
+
+func (r *Routes) PGX(name string) (*RoutedConn[dbtypes.PgxConn, dbtypes.PgxTx], bool) {
+	if r == nil {
+		return nil, false
+	}
+
+	rc, ok := r.pgx[name]
+	return rc, ok
+}
@@ -0,0 +1,87 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nenormalka/freya/conns/connectors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type countConn struct {
+	calls int
+	txs   int
+}
+
+func (c *countConn) CallContext(
+	ctx context.Context,
+	_ string,
+	callFunc func(ctx context.Context, db *sqlx.DB) error,
+) error {
+	c.calls++
+	return callFunc(ctx, nil)
+}
+
+func (c *countConn) CallTransaction(
+	ctx context.Context,
+	_ string,
+	callFunc func(ctx context.Context, tx *sqlx.Tx) error,
+	_ ...connectors.TxOption,
+) error {
+	c.txs++
+	return callFunc(ctx, nil)
+}
+
+func TestRoutedConn(t *testing.T) {
+	primary, replica1, replica2 := &countConn{}, &countConn{}, &countConn{}
+	errDown := errors.New("replica is down")
+	replica2Down := false
+
+	rc, err := newRoutedConn(RouteConfig{
+		Name:     "users",
+		Primary:  "master",
+		Replicas: []string{"replica1", "replica2"},
+		Balancer: BalancerRoundRobin,
+	}, map[string]connectors.DBConnector[*sqlx.DB, *sqlx.Tx]{
+		"master":   primary,
+		"replica1": replica1,
+		"replica2": replica2,
+	}, map[string]func(ctx context.Context) error{
+		"replica1": func(context.Context) error { return nil },
+		"replica2": func(context.Context) error {
+			if replica2Down {
+				return errDown
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	noop := func(context.Context, *sqlx.DB) error { return nil }
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, rc.CallContext(ctx, "get_user", noop))
+	}
+	require.NoError(t, rc.CallTransaction(ctx, "update_user", func(context.Context, *sqlx.Tx) error { return nil }))
+	require.NoError(t, rc.CallContext(ForcePrimary(ctx), "get_user", noop))
+
+	require.Equal(t, 2, replica1.calls)
+	require.Equal(t, 2, replica2.calls)
+	require.Equal(t, 1, primary.calls)
+	require.Equal(t, 1, primary.txs)
+
+	// упавшая реплика выводится из ротации
+	replica2Down = true
+	rc.check(ctx, zap.NewNop())
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, rc.CallContext(ctx, "get_user", noop))
+	}
+	require.Equal(t, 6, replica1.calls)
+	require.Equal(t, 2, replica2.calls)
+}
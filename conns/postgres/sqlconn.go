@@ -9,11 +9,13 @@ import (
 
 	"github.com/nenormalka/freya/config"
 	"github.com/nenormalka/freya/conns/connectors"
+	"github.com/nenormalka/freya/conns/resilience"
 	"github.com/nenormalka/freya/types"
 )
 
 type (
 	SQLConn struct {
+		name    string
 		db      *sqlx.DB
 		logger  *zap.Logger
 		appName string
@@ -24,15 +26,17 @@ func NewSQLConnector(
 	poolDB map[string]*sqlx.DB,
 	logger *zap.Logger,
 	cfg *config.Config,
+	policies *resilience.Policies,
 ) map[string]connectors.DBConnector[*sqlx.DB, *sqlx.Tx] {
 	return newConns[connectors.DBConnector[*sqlx.DB, *sqlx.Tx]](
 		poolDB,
 		func(nameConn string) connectors.DBConnector[*sqlx.DB, *sqlx.Tx] {
-			return &SQLConn{
+			return resilience.Wrap[*sqlx.DB, *sqlx.Tx](&SQLConn{
+				name:    nameConn,
 				db:      poolDB[nameConn],
 				logger:  logger,
 				appName: cfg.AppName,
-			}
+			}, policies.Get(CheckName(ConnTypeSQLX, nameConn)))
 		})
 }
 
@@ -41,6 +45,12 @@ func (s *SQLConn) CallContext(
 	queryName string,
 	callFunc func(ctx context.Context, db *sqlx.DB) error,
 ) error {
+	if InUnitOfWork(ctx, s.name) {
+		return ErrCallContextInUnitOfWork
+	}
+
+	ctx = withMetricsService(ctx, s.appName)
+
 	return types.WithSQLMetrics(queryName, s.appName, func() error {
 		return callFunc(ctx, s.db)
 	})
@@ -50,21 +60,53 @@ func (s *SQLConn) CallTransaction(
 	ctx context.Context,
 	txName string,
 	callFunc func(ctx context.Context, tx *sqlx.Tx) error,
+	opts ...connectors.TxOption,
 ) error {
+	ctx = withMetricsService(ctx, s.appName)
+
+	if active := activeTxFromContext(ctx, s.name); active != nil && active.sqlTx != nil {
+		return types.WithSQLMetrics(txName, s.appName, func() error {
+			return active.savepoint(ctx, s.name, func(ctx context.Context, nested *activeTx) error {
+				return callFunc(ctx, nested.sqlTx)
+			})
+		})
+	}
+
+	mode, retry, err := txSettings(ctx, opts)
+	if err != nil {
+		return err
+	}
+
 	return types.WithSQLMetrics(txName, s.appName, func() error {
-		tx, err := s.db.BeginTxx(ctx, nil)
-		if err != nil {
-			return err
-		}
+		return withTxRetry(ctx, txName, retry, func() error {
+			return s.transaction(ctx, txName, mode, callFunc)
+		})
+	})
+}
 
-		if err = callFunc(ctx, tx); err != nil {
-			if rErr := tx.Rollback(); rErr != nil {
-				s.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
-			}
+func (s *SQLConn) transaction(
+	ctx context.Context,
+	txName string,
+	opts TxOptions,
+	callFunc func(ctx context.Context, tx *sqlx.Tx) error,
+) error {
+	tx, err := s.db.BeginTxx(ctx, opts.sqlOptions())
+	if err != nil {
+		return err
+	}
 
-			return err
+	if err = setDeferrable(ctx, tx.Tx, opts); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err = callFunc(ctx, tx); err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			s.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
 		}
 
-		return tx.Commit()
-	})
+		return err
+	}
+
+	return tx.Commit()
 }
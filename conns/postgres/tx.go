@@ -0,0 +1,193 @@
+package postrgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nenormalka/freya/conns/connectors"
+	"github.com/nenormalka/freya/types"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	IsolationDefault IsolationLevel = iota
+	IsolationReadCommitted
+	IsolationRepeatableRead
+	IsolationSerializable
+)
+
+const (
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+
+	defaultTxRetryBackoff = 10 * time.Millisecond
+)
+
+var (
+	ErrDeferrableMode = errors.New("deferrable transaction must be serializable and read only")
+)
+
+type (
+	IsolationLevel int
+
+	// TxOptions режим транзакции для CallTransaction, задаётся опцией TxModeOption или через WithTxOptions
+	TxOptions struct {
+		Isolation IsolationLevel
+		ReadOnly  bool
+		// Deferrable только вместе с IsolationSerializable и ReadOnly, иначе CallTransaction вернёт ErrDeferrableMode
+		Deferrable bool
+	}
+
+	// TxRetry повтор всей транзакции при ошибках сериализации (40001) и дедлоках (40P01).
+	// callFunc при этом вызывается заново, так что он не должен иметь побочных эффектов вне транзакции.
+	TxRetry struct {
+		// MaxAttempts общее количество попыток, включая первую
+		MaxAttempts int
+		// Backoff пауза перед первым повтором, дальше удваивается, но не больше MaxBackoff
+		Backoff    time.Duration
+		MaxBackoff time.Duration
+	}
+
+	txOptionsKey struct{}
+	txRetryKey   struct{}
+)
+
+// WithTxOptions задаёт режим транзакции для CallTransaction с этим контекстом
+func WithTxOptions(ctx context.Context, opts TxOptions) context.Context {
+	return context.WithValue(ctx, txOptionsKey{}, opts)
+}
+
+// WithTxRetry включает повтор транзакции для CallTransaction с этим контекстом
+func WithTxRetry(ctx context.Context, retry TxRetry) context.Context {
+	return context.WithValue(ctx, txRetryKey{}, retry)
+}
+
+// TxModeOption задаёт режим одной транзакции, перекрывает WithTxOptions из контекста
+func TxModeOption(opts TxOptions) connectors.TxOption {
+	return func(ctx context.Context) context.Context {
+		return WithTxOptions(ctx, opts)
+	}
+}
+
+// TxRetryOption включает повтор одной транзакции, перекрывает WithTxRetry из контекста
+func TxRetryOption(retry TxRetry) connectors.TxOption {
+	return func(ctx context.Context) context.Context {
+		return WithTxRetry(ctx, retry)
+	}
+}
+
+// txSettings режим и повтор транзакции: опции вызова поверх контекста. Контекст с опциями дальше
+// не передаётся, чтобы они не достались транзакциям, открытым уже внутри callFunc.
+func txSettings(ctx context.Context, opts []connectors.TxOption) (TxOptions, TxRetry, error) {
+	ctx = connectors.ApplyTxOptions(ctx, opts...)
+
+	retry, _ := ctx.Value(txRetryKey{}).(TxRetry)
+
+	mode, _ := ctx.Value(txOptionsKey{}).(TxOptions)
+	if mode.Deferrable && (mode.Isolation != IsolationSerializable || !mode.ReadOnly) {
+		return mode, retry, ErrDeferrableMode
+	}
+
+	return mode, retry, nil
+}
+
+func (o TxOptions) sqlOptions() *sql.TxOptions {
+	if o == (TxOptions{}) {
+		return nil
+	}
+
+	opts := &sql.TxOptions{ReadOnly: o.ReadOnly}
+
+	switch o.Isolation {
+	case IsolationReadCommitted:
+		opts.Isolation = sql.LevelReadCommitted
+	case IsolationRepeatableRead:
+		opts.Isolation = sql.LevelRepeatableRead
+	case IsolationSerializable:
+		opts.Isolation = sql.LevelSerializable
+	}
+
+	return opts
+}
+
+func (o TxOptions) pgxOptions() pgx.TxOptions {
+	opts := pgx.TxOptions{}
+
+	switch o.Isolation {
+	case IsolationReadCommitted:
+		opts.IsoLevel = pgx.ReadCommitted
+	case IsolationRepeatableRead:
+		opts.IsoLevel = pgx.RepeatableRead
+	case IsolationSerializable:
+		opts.IsoLevel = pgx.Serializable
+	}
+
+	if o.ReadOnly {
+		opts.AccessMode = pgx.ReadOnly
+	}
+
+	if o.Deferrable {
+		opts.DeferrableMode = pgx.Deferrable
+	}
+
+	return opts
+}
+
+// setDeferrable database/sql не умеет DEFERRABLE, поэтому ставим его первым запросом транзакции
+func setDeferrable(ctx context.Context, tx *sql.Tx, opts TxOptions) error {
+	if !opts.Deferrable {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, "SET TRANSACTION DEFERRABLE")
+
+	return err
+}
+
+// withTxRetry вызывает f, пока она падает на ошибках сериализации, если повтор включён.
+// Каждый повтор считается в DBTxRetryMetrics под txName.
+func withTxRetry(ctx context.Context, txName string, retry TxRetry, f func() error) error {
+	if retry.MaxAttempts <= 1 {
+		return f()
+	}
+
+	backoff := retry.Backoff
+	if backoff <= 0 {
+		backoff = defaultTxRetryBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= retry.MaxAttempts || !isRetryableTxError(err) {
+			return err
+		}
+
+		types.DBTxRetryMetrics.WithLabelValues(txName).Inc()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
+
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == serializationFailureCode || pgErr.Code == deadlockDetectedCode
+}
@@ -0,0 +1,83 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nenormalka/freya/conns/connectors"
+	"github.com/nenormalka/freya/types"
+
+	"github.com/jackc/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTxRetry(t *testing.T) {
+	serializationErr := fmt.Errorf("commit: %w", &pgconn.PgError{Code: serializationFailureCode})
+
+	calls := 0
+	failTwice := func() error {
+		calls++
+		if calls < 3 {
+			return serializationErr
+		}
+		return nil
+	}
+
+	// без повтора одна попытка
+	require.ErrorIs(t, withTxRetry(context.Background(), "tx", TxRetry{}, failTwice), serializationErr)
+	require.Equal(t, 1, calls)
+
+	calls = 0
+	retry := TxRetry{MaxAttempts: 3, Backoff: time.Millisecond}
+	require.NoError(t, withTxRetry(context.Background(), "retried_tx", retry, failTwice))
+	require.Equal(t, 3, calls)
+
+	// повторы считаются отдельно от метрики запросов
+	require.Equal(t, float64(2), testutil.ToFloat64(types.DBTxRetryMetrics.WithLabelValues("retried_tx")))
+
+	// остальные ошибки не повторяются
+	calls = 0
+	errOther := errors.New("other")
+	require.ErrorIs(t, withTxRetry(context.Background(), "tx", retry, func() error {
+		calls++
+		return errOther
+	}), errOther)
+	require.Equal(t, 1, calls)
+}
+
+func TestTxSettings(t *testing.T) {
+	serializable := TxOptions{Isolation: IsolationSerializable, ReadOnly: true, Deferrable: true}
+
+	_, _, err := txSettings(WithTxOptions(context.Background(), serializable), nil)
+	require.NoError(t, err)
+
+	_, _, err = txSettings(WithTxOptions(context.Background(), TxOptions{
+		Isolation:  IsolationSerializable,
+		Deferrable: true,
+	}), nil)
+	require.ErrorIs(t, err, ErrDeferrableMode)
+
+	_, _, err = txSettings(context.Background(), []connectors.TxOption{
+		TxModeOption(TxOptions{ReadOnly: true, Deferrable: true}),
+	})
+	require.ErrorIs(t, err, ErrDeferrableMode)
+
+	// опции вызова перекрывают контекст
+	ctx := WithTxRetry(WithTxOptions(context.Background(), serializable), TxRetry{MaxAttempts: 2})
+	mode, retry, err := txSettings(ctx, []connectors.TxOption{
+		TxModeOption(TxOptions{Isolation: IsolationRepeatableRead}),
+		TxRetryOption(TxRetry{MaxAttempts: 5}),
+	})
+	require.NoError(t, err)
+	require.Equal(t, TxOptions{Isolation: IsolationRepeatableRead}, mode)
+	require.Equal(t, 5, retry.MaxAttempts)
+
+	mode, retry, err = txSettings(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, serializable, mode)
+	require.Equal(t, 2, retry.MaxAttempts)
+}
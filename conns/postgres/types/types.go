@@ -7,12 +7,15 @@ import (
 	"fmt"
 
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgtype/pgxtype"
 	"github.com/jackc/pgx/v4"
 )
 
 var (
 	ErrAssertionFailed = errors.New("type assertion failed")
+	// ErrNotSupported querier соединения не умеет COPY или батчи, например мок в тестах
+	ErrNotSupported = errors.New("operation is not supported by querier")
 )
 
 type (
@@ -37,8 +40,71 @@ type (
 	Scanner interface {
 		Scan(src any) error
 	}
+
+	copier interface {
+		CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	}
+
+	batcher interface {
+		SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	}
+
+	// errBatchResults результаты батча, который не удалось отправить
+	errBatchResults struct {
+		err error
+	}
+
+	errRow struct {
+		err error
+	}
 )
 
+// CopyFrom пул и транзакция умеют COPY, а PgxQuerier его не требует, чтобы не ломать свои реализации
+func (c PgxConn) CopyFrom(
+	ctx context.Context,
+	tableName pgx.Identifier,
+	columnNames []string,
+	rowSrc pgx.CopyFromSource,
+) (int64, error) {
+	if cp, ok := c.PgxQuerier.(copier); ok {
+		return cp.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	}
+
+	return 0, fmt.Errorf("copy from: %w", ErrNotSupported)
+}
+
+func (c PgxConn) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	if br, ok := c.PgxQuerier.(batcher); ok {
+		return br.SendBatch(ctx, b)
+	}
+
+	return errBatchResults{err: fmt.Errorf("send batch: %w", ErrNotSupported)}
+}
+
+func (r errBatchResults) Exec() (pgconn.CommandTag, error) {
+	return nil, r.err
+}
+
+func (r errBatchResults) Query() (pgx.Rows, error) {
+	return nil, r.err
+}
+
+func (r errBatchResults) QueryRow() pgx.Row {
+	return errRow{err: r.err}
+}
+
+func (r errBatchResults) QueryFunc([]any, func(pgx.QueryFuncRow) error) (pgconn.CommandTag, error) {
+	return nil, r.err
+}
+
+func (r errBatchResults) Close() error {
+	return r.err
+}
+
+func (r errRow) Scan(...any) error {
+	return r.err
+}
+
 func (p *PgxTx) Select(ctx context.Context, dst any, query string, args ...any) error {
 	return pgxscan.Select(ctx, p, dst, query, args...)
 }
@@ -0,0 +1,198 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nenormalka/freya/config"
+	"github.com/nenormalka/freya/conns/connectors"
+	"github.com/nenormalka/freya/conns/resilience"
+	"github.com/nenormalka/freya/types"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type (
+	// UnitOfWork открывает транзакцию и кладёт её в контекст. Пока она активна, CallTransaction
+	// на том же соединении выполняются внутри неё через SAVEPOINT, а CallContext идёт в неё же.
+	// Так несколько репозиториев могут работать в одной транзакции, не передавая её руками.
+	UnitOfWork struct {
+		sqlxPoolDB map[string]*sqlx.DB
+		pgxPoolDB  map[string]*pgxpool.Pool
+		policies   *resilience.Policies
+		logger     *zap.Logger
+		appName    string
+	}
+
+	// activeTx транзакция unit of work, заполнен либо sqlTx, либо pgxTx в зависимости от типа соединения
+	activeTx struct {
+		sqlTx *sqlx.Tx
+		pgxTx pgx.Tx
+		depth int
+	}
+
+	uowKey struct {
+		nameConn string
+	}
+)
+
+var (
+	// ErrCallContextInUnitOfWork sqlx и goqu отдают в CallContext пул, а не транзакцию, поэтому внутри
+	// unit of work запросы надо делать через CallTransaction, иначе они ушли бы мимо транзакции
+	ErrCallContextInUnitOfWork = errors.New("CallContext can not run inside unit of work on sqlx/goqu connection, use CallTransaction")
+	ErrUnknownConn             = errors.New("unknown db connection")
+)
+
+func NewUnitOfWork(
+	sqlxPoolDB map[string]*sqlx.DB,
+	pgxPoolDB map[string]*pgxpool.Pool,
+	logger *zap.Logger,
+	cfg *config.Config,
+	policies *resilience.Policies,
+) *UnitOfWork {
+	return &UnitOfWork{
+		sqlxPoolDB: sqlxPoolDB,
+		pgxPoolDB:  pgxPoolDB,
+		policies:   policies,
+		logger:     logger,
+		appName:    cfg.AppName,
+	}
+}
+
+// Do выполняет f в транзакции соединения nameConn. Если в контексте уже есть транзакция этого соединения,
+// f выполняется внутри неё под SAVEPOINT. Режим и повтор (опции вызова, WithTxOptions и WithTxRetry)
+// применяются только к внешней транзакции.
+func (u *UnitOfWork) Do(
+	ctx context.Context,
+	nameConn, txName string,
+	f func(ctx context.Context) error,
+	opts ...connectors.TxOption,
+) error {
+	if active := activeTxFromContext(ctx, nameConn); active != nil {
+		return active.savepoint(ctx, nameConn, func(ctx context.Context, _ *activeTx) error {
+			return f(ctx)
+		})
+	}
+
+	mode, retry, err := txSettings(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// та же политика, что у коннекторов соединения, вложенные вызовы её повторно не проходят
+	return u.policies.Get(u.policyName(nameConn)).Do(ctx, func(ctx context.Context) error {
+		return types.WithSQLMetrics(txName, u.appName, func() error {
+			return withTxRetry(ctx, txName, retry, func() error {
+				return u.begin(ctx, nameConn, txName, mode, f)
+			})
+		})
+	})
+}
+
+func (u *UnitOfWork) policyName(nameConn string) string {
+	if _, ok := u.pgxPoolDB[nameConn]; ok {
+		return CheckName(ConnTypePGX, nameConn)
+	}
+
+	return CheckName(ConnTypeSQLX, nameConn)
+}
+
+func (u *UnitOfWork) begin(
+	ctx context.Context,
+	nameConn, txName string,
+	opts TxOptions,
+	f func(ctx context.Context) error,
+) error {
+	if db, ok := u.sqlxPoolDB[nameConn]; ok {
+		tx, err := db.BeginTxx(ctx, opts.sqlOptions())
+		if err != nil {
+			return err
+		}
+
+		if err = setDeferrable(ctx, tx.Tx, opts); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+
+		if err = f(withActiveTx(ctx, nameConn, &activeTx{sqlTx: tx})); err != nil {
+			if rErr := tx.Rollback(); rErr != nil {
+				u.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
+			}
+
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	if pool, ok := u.pgxPoolDB[nameConn]; ok {
+		tx, err := pool.BeginTx(ctx, opts.pgxOptions())
+		if err != nil {
+			return err
+		}
+
+		if err = f(withActiveTx(ctx, nameConn, &activeTx{pgxTx: tx})); err != nil {
+			if rErr := tx.Rollback(ctx); rErr != nil {
+				u.logger.Error(fmt.Sprintf("failed rollback transaction: %s", txName), zap.Error(rErr))
+			}
+
+			return err
+		}
+
+		return tx.Commit(ctx)
+	}
+
+	return fmt.Errorf("%s: %w", nameConn, ErrUnknownConn)
+}
+
+// savepoint выполняет f во вложенной транзакции. У pgx для этого есть Begin на транзакции,
+// для sqlx точки сохранения ставятся руками.
+func (a *activeTx) savepoint(
+	ctx context.Context,
+	nameConn string,
+	f func(ctx context.Context, nested *activeTx) error,
+) error {
+	if a.pgxTx != nil {
+		return a.pgxTx.BeginFunc(ctx, func(tx pgx.Tx) error {
+			nested := &activeTx{pgxTx: tx, depth: a.depth + 1}
+			return f(withActiveTx(ctx, nameConn, nested), nested)
+		})
+	}
+
+	nested := &activeTx{sqlTx: a.sqlTx, depth: a.depth + 1}
+	name := fmt.Sprintf("freya_sp_%d", nested.depth)
+
+	if _, err := a.sqlTx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	if err := f(withActiveTx(ctx, nameConn, nested), nested); err != nil {
+		if _, rErr := a.sqlTx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rErr != nil {
+			return errors.Join(err, rErr)
+		}
+
+		return err
+	}
+
+	_, err := a.sqlTx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+
+	return err
+}
+
+func withActiveTx(ctx context.Context, nameConn string, tx *activeTx) context.Context {
+	return context.WithValue(ctx, uowKey{nameConn: nameConn}, tx)
+}
+
+func activeTxFromContext(ctx context.Context, nameConn string) *activeTx {
+	tx, _ := ctx.Value(uowKey{nameConn: nameConn}).(*activeTx)
+	return tx
+}
+
+// InUnitOfWork есть ли в контексте активная транзакция соединения nameConn
+func InUnitOfWork(ctx context.Context, nameConn string) bool {
+	return activeTxFromContext(ctx, nameConn) != nil
+}
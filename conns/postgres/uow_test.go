@@ -0,0 +1,74 @@
+package postrgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nenormalka/freya/config"
+	"github.com/nenormalka/freya/conns/resilience"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/doug-martin/goqu/v9"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	// bulkhead на одно место: вложенные вызовы не должны повторно занимать его
+	policies := resilience.NewPolicies(resilience.Params{Config: resilience.Config{
+		Policies: []resilience.PolicyConfig{{Name: CheckName(ConnTypeSQLX, "master"), MaxConcurrent: 1, Timeout: time.Second}},
+	}})
+	uow := NewUnitOfWork(map[string]*sqlx.DB{"master": db}, nil, zap.NewNop(), &config.Config{}, policies)
+	conn := resilience.Wrap[*sqlx.DB, *sqlx.Tx](
+		&SQLConn{name: "master", db: db, logger: zap.NewNop()},
+		policies.Get(CheckName(ConnTypeSQLX, "master")),
+	)
+	goquConn := &GoQuConn{name: "master", db: db, logger: zap.NewNop()}
+
+	insert := func(ctx context.Context, tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users DEFAULT VALUES")
+		return err
+	}
+
+	// у sqlx и goqu CallContext внутри unit of work ушёл бы мимо транзакции, поэтому запрещён
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT freya_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO users DEFAULT VALUES").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT freya_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, uow.Do(context.Background(), "master", "create_user", func(ctx context.Context) error {
+		require.ErrorIs(t, conn.CallContext(ctx, "get_user", func(context.Context, *sqlx.DB) error {
+			return nil
+		}), ErrCallContextInUnitOfWork)
+
+		require.ErrorIs(t, goquConn.CallContext(ctx, "rename_user", func(context.Context, *goqu.Database) error {
+			return nil
+		}), ErrCallContextInUnitOfWork)
+
+		return conn.CallTransaction(ctx, "insert_user", insert)
+	}))
+
+	// ошибка вложенного вызова откатывает его точку сохранения, а затем и всю транзакцию
+	errFailed := errors.New("failed")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT freya_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT freya_sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	require.ErrorIs(t, uow.Do(context.Background(), "master", "create_user", func(ctx context.Context) error {
+		return conn.CallTransaction(ctx, "insert_user", func(context.Context, *sqlx.Tx) error {
+			return errFailed
+		})
+	}), errFailed)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,51 @@
+package resilience
+
+import (
+	"time"
+
+	"github.com/nenormalka/freya/config"
+)
+
+const (
+	defaultOpenTimeout = 10 * time.Second
+)
+
+type (
+	Config struct {
+		Policies []PolicyConfig
+	}
+
+	PolicyConfig struct {
+		Name             string
+		MaxConcurrent    int
+		MaxWait          time.Duration
+		Timeout          time.Duration
+		FailureThreshold int
+		OpenTimeout      time.Duration
+	}
+)
+
+func NewConfig(cfg *config.Config) Config {
+	policies := make([]PolicyConfig, 0, len(cfg.Resilience))
+
+	for _, r := range cfg.Resilience {
+		policy := PolicyConfig{
+			Name:             r.Name,
+			MaxConcurrent:    r.MaxConcurrent,
+			MaxWait:          r.MaxWait,
+			Timeout:          r.Timeout,
+			FailureThreshold: r.FailureThreshold,
+			OpenTimeout:      r.OpenTimeout,
+		}
+
+		if policy.OpenTimeout == 0 {
+			policy.OpenTimeout = defaultOpenTimeout
+		}
+
+		policies = append(policies, policy)
+	}
+
+	return Config{
+		Policies: policies,
+	}
+}
@@ -0,0 +1,253 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/nenormalka/freya/types"
+	ferrors "github.com/nenormalka/freya/types/errors"
+)
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+const (
+	reasonBreakerOpen  = "breaker_open"
+	reasonBulkheadFull = "bulkhead_full"
+)
+
+type (
+	State int
+
+	// Policy breaker, ограничение одновременных вызовов (bulkhead) и таймаут для одного соединения.
+	// nil политика просто вызывает функцию.
+	Policy struct {
+		cfg         PolicyConfig
+		classifiers ferrors.Classifiers
+		sem         chan struct{}
+
+		mu       sync.Mutex
+		state    State
+		failures int
+		openedAt time.Time
+		probing  bool
+	}
+
+	// heldKey отмечает в контексте, что вызов уже идёт под политикой
+	heldKey struct {
+		policy *Policy
+	}
+)
+
+var (
+	ErrBreakerOpen  = errors.New("circuit breaker is open")
+	ErrBulkheadFull = errors.New("too many concurrent calls")
+
+	// failureCodes коды, которые говорят о проблеме с зависимостью, а не с запросом
+	failureCodes = map[ferrors.Code]struct{}{
+		ferrors.Unknown:          {},
+		ferrors.Internal:         {},
+		ferrors.Unavailable:      {},
+		ferrors.DeadlineExceeded: {},
+	}
+)
+
+func NewPolicy(cfg PolicyConfig, classifiers ferrors.Classifiers) *Policy {
+	p := &Policy{
+		cfg:         cfg,
+		classifiers: classifiers,
+	}
+
+	if cfg.MaxConcurrent > 0 {
+		p.sem = make(chan struct{}, cfg.MaxConcurrent)
+	}
+
+	types.ResilienceBreakerStateMetrics.WithLabelValues(cfg.Name).Set(float64(StateClosed))
+	types.ResilienceInFlightMetrics.WithLabelValues(cfg.Name).Set(0)
+
+	return p
+}
+
+// Do выполняет f с учётом breaker'а, bulkhead'а и таймаута. Отказы возвращаются как Unavailable,
+// так что grpc и http отдадут их без дополнительной обработки.
+func (p *Policy) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	// вложенные вызовы, например репозитории внутри unit of work, уже заняли место в bulkhead,
+	// повторный захват при маленьком MaxConcurrent мог бы повиснуть
+	if p == nil || ctx.Value(heldKey{policy: p}) != nil {
+		return f(ctx)
+	}
+
+	if !p.allow() {
+		types.ResilienceRejectedMetrics.WithLabelValues(p.cfg.Name, reasonBreakerOpen).Inc()
+		return ferrors.NewUnavailableError(ErrBreakerOpen)
+	}
+
+	if err := p.acquire(ctx); err != nil {
+		p.abort()
+		types.ResilienceRejectedMetrics.WithLabelValues(p.cfg.Name, reasonBulkheadFull).Inc()
+		return err
+	}
+	defer p.release()
+
+	if p.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.Timeout)
+		defer cancel()
+	}
+
+	err := f(context.WithValue(ctx, heldKey{policy: p}, true))
+	p.done(err)
+
+	return err
+}
+
+func (p *Policy) Name() string {
+	return p.cfg.Name
+}
+
+func (p *Policy) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.state
+}
+
+// Check для health: ошибка, пока breaker разомкнут
+func (p *Policy) Check(_ context.Context) error {
+	if p.State() == StateOpen {
+		return ErrBreakerOpen
+	}
+
+	return nil
+}
+
+func (p *Policy) allow() bool {
+	if p.cfg.FailureThreshold <= 0 {
+		return true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch p.state {
+	case StateOpen:
+		if time.Since(p.openedAt) < p.cfg.OpenTimeout {
+			return false
+		}
+
+		p.setState(StateHalfOpen)
+		p.probing = true
+
+		return true
+	case StateHalfOpen:
+		// пока пробный вызов не вернулся, остальные получают отказ
+		if p.probing {
+			return false
+		}
+
+		p.probing = true
+
+		return true
+	default:
+		return true
+	}
+}
+
+// done учитывает результат вызова
+func (p *Policy) done(err error) {
+	if p.cfg.FailureThreshold <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.probing = false
+
+	if !p.isFailure(err) {
+		p.failures = 0
+		if p.state != StateClosed {
+			p.setState(StateClosed)
+		}
+
+		return
+	}
+
+	p.failures++
+
+	if p.state == StateHalfOpen || p.failures >= p.cfg.FailureThreshold {
+		p.openedAt = time.Now()
+		p.setState(StateOpen)
+	}
+}
+
+// abort освобождает пробу, если вызов так и не состоялся
+func (p *Policy) abort() {
+	p.mu.Lock()
+	p.probing = false
+	p.mu.Unlock()
+}
+
+func (p *Policy) isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var e *ferrors.Error
+	if !errors.As(p.classifiers.Classify(err), &e) {
+		return true
+	}
+
+	_, ok := failureCodes[e.Code]
+
+	return ok
+}
+
+func (p *Policy) setState(state State) {
+	p.state = state
+	types.ResilienceBreakerStateMetrics.WithLabelValues(p.cfg.Name).Set(float64(state))
+}
+
+func (p *Policy) acquire(ctx context.Context) error {
+	if p.sem == nil {
+		types.ResilienceInFlightMetrics.WithLabelValues(p.cfg.Name).Inc()
+		return nil
+	}
+
+	select {
+	case p.sem <- struct{}{}:
+		types.ResilienceInFlightMetrics.WithLabelValues(p.cfg.Name).Inc()
+		return nil
+	default:
+	}
+
+	if p.cfg.MaxWait <= 0 {
+		return ferrors.NewUnavailableError(ErrBulkheadFull)
+	}
+
+	timer := time.NewTimer(p.cfg.MaxWait)
+	defer timer.Stop()
+
+	select {
+	case p.sem <- struct{}{}:
+		types.ResilienceInFlightMetrics.WithLabelValues(p.cfg.Name).Inc()
+		return nil
+	case <-timer.C:
+		return ferrors.NewUnavailableError(ErrBulkheadFull)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *Policy) release() {
+	types.ResilienceInFlightMetrics.WithLabelValues(p.cfg.Name).Dec()
+
+	if p.sem != nil {
+		<-p.sem
+	}
+}
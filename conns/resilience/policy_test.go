@@ -0,0 +1,82 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyBreaker(t *testing.T) {
+	p := NewPolicy(PolicyConfig{
+		Name:             "test_breaker",
+		FailureThreshold: 2,
+		OpenTimeout:      20 * time.Millisecond,
+	}, ferrors.DefaultClassifiers)
+
+	errDown := errors.New("connection refused")
+	fail := func(context.Context) error { return errDown }
+	ok := func(context.Context) error { return nil }
+
+	// NotFound ошибка запроса, а не зависимости, breaker её не считает
+	for i := 0; i < 3; i++ {
+		require.Error(t, p.Do(context.Background(), func(context.Context) error {
+			return ferrors.NewNotFoundError(errDown)
+		}))
+	}
+	require.Equal(t, StateClosed, p.State())
+
+	require.ErrorIs(t, p.Do(context.Background(), fail), errDown)
+	require.ErrorIs(t, p.Do(context.Background(), fail), errDown)
+	require.Equal(t, StateOpen, p.State())
+	require.ErrorIs(t, p.Check(context.Background()), ErrBreakerOpen)
+
+	err := p.Do(context.Background(), ok)
+	require.ErrorIs(t, err, ErrBreakerOpen)
+
+	var e *ferrors.Error
+	require.ErrorAs(t, err, &e)
+	require.Equal(t, ferrors.Unavailable, e.Code)
+
+	time.Sleep(30 * time.Millisecond)
+
+	require.NoError(t, p.Do(context.Background(), ok))
+	require.Equal(t, StateClosed, p.State())
+}
+
+func TestPolicyBulkhead(t *testing.T) {
+	p := NewPolicy(PolicyConfig{
+		Name:          "test_bulkhead",
+		MaxConcurrent: 1,
+		Timeout:       time.Second,
+	}, ferrors.DefaultClassifiers)
+
+	started, release := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		_ = p.Do(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+
+	<-started
+	require.ErrorIs(t, p.Do(context.Background(), func(context.Context) error { return nil }), ErrBulkheadFull)
+	close(release)
+
+	// вложенный вызов под той же политикой не занимает второе место
+	nested := NewPolicy(PolicyConfig{
+		Name:          "test_bulkhead_nested",
+		MaxConcurrent: 1,
+		Timeout:       time.Second,
+	}, ferrors.DefaultClassifiers)
+
+	require.NoError(t, nested.Do(context.Background(), func(ctx context.Context) error {
+		return nested.Do(ctx, func(context.Context) error { return nil })
+	}))
+}
@@ -0,0 +1,106 @@
+package resilience
+
+import (
+	"context"
+	"sort"
+
+	"github.com/nenormalka/freya/conns/connectors"
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"go.uber.org/dig"
+)
+
+type (
+	Params struct {
+		dig.In
+
+		Config      Config
+		Classifiers []ferrors.Classifier `group:"error_classifiers"`
+	}
+
+	// Policies политики по имени соединения. Соединения без политики вызываются напрямую.
+	Policies struct {
+		policies map[string]*Policy
+	}
+
+	connector[T connectors.ConnectDB, M connectors.ConnectTx] struct {
+		next   connectors.DBConnector[T, M]
+		policy *Policy
+	}
+)
+
+func NewPolicies(p Params) *Policies {
+	classifiers := append(ferrors.Classifiers(p.Classifiers), ferrors.DefaultClassifiers...)
+	policies := make(map[string]*Policy, len(p.Config.Policies))
+
+	for _, cfg := range p.Config.Policies {
+		policies[cfg.Name] = NewPolicy(cfg, classifiers)
+	}
+
+	return &Policies{
+		policies: policies,
+	}
+}
+
+// Get возвращает политику соединения или nil, если она не настроена
+func (p *Policies) Get(name string) *Policy {
+	if p == nil {
+		return nil
+	}
+
+	return p.policies[name]
+}
+
+// List возвращает все политики, отсортированные по имени
+func (p *Policies) List() []*Policy {
+	if p == nil {
+		return nil
+	}
+
+	list := make([]*Policy, 0, len(p.policies))
+	for _, policy := range p.policies {
+		list = append(list, policy)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+
+	return list
+}
+
+// Wrap оборачивает коннектор политикой. С nil политикой коннектор возвращается как есть.
+func Wrap[T connectors.ConnectDB, M connectors.ConnectTx](
+	conn connectors.DBConnector[T, M],
+	policy *Policy,
+) connectors.DBConnector[T, M] {
+	if policy == nil || conn == nil {
+		return conn
+	}
+
+	return &connector[T, M]{
+		next:   conn,
+		policy: policy,
+	}
+}
+
+func (c *connector[T, M]) CallContext(
+	ctx context.Context,
+	queryName string,
+	callFunc func(ctx context.Context, db T) error,
+) error {
+	return c.policy.Do(ctx, func(ctx context.Context) error {
+		return c.next.CallContext(ctx, queryName, callFunc)
+	})
+}
+
+func (c *connector[T, M]) CallTransaction(
+	ctx context.Context,
+	txName string,
+	callFunc func(ctx context.Context, tx M) error,
+	opts ...connectors.TxOption,
+) error {
+	return c.policy.Do(ctx, func(ctx context.Context) error {
+		return c.next.CallTransaction(ctx, txName, callFunc, opts...)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"syscall"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/nenormalka/freya/apm"
 	"github.com/nenormalka/freya/config"
 	"github.com/nenormalka/freya/conns"
+	"github.com/nenormalka/freya/conns/postgres/migrations"
 	"github.com/nenormalka/freya/grpc"
 	"github.com/nenormalka/freya/http"
 	"github.com/nenormalka/freya/logger"
@@ -44,6 +46,12 @@ type (
 		ServerList  types.ServerList
 	}
 
+	HealthAdapterOut struct {
+		dig.Out
+
+		HealthChecks []types.HealthCheck `group:"health_checkers,flatten"`
+	}
+
 	Engine struct {
 		container *dig.Container
 	}
@@ -51,9 +59,9 @@ type (
 
 var defaultModules = types.Module{
 	{CreateFunc: ServiceAdapter},
+	{CreateFunc: HealthAdapter},
 	{CreateFunc: NewApp},
-	{CreateFunc: types.NewServicePool},
-	{CreateFunc: types.NewServerPool},
+	{CreateFunc: types.NewProbe},
 	{CreateFunc: NewShutdownContext},
 	{CreateFunc: logger.NewLogger},
 }.
@@ -71,6 +79,34 @@ func ServiceAdapter(in ServiceAdapterIn) ServiceAdapterOut {
 	}
 }
 
+// HealthAdapter отдаёт в группу `health_checkers` проверки всех соединений из conns. Проверки из
+// HEALTH_CRITICAL_CHECKS роняют статус, остальные только отображаются в ответе.
+func HealthAdapter(cfg *config.Config, conns *conns.Conns) HealthAdapterOut {
+	critical := make(map[string]struct{})
+	for _, name := range strings.Split(cfg.Health.CriticalChecks, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			critical[name] = struct{}{}
+		}
+	}
+
+	checks := conns.HealthChecks()
+	out := HealthAdapterOut{
+		HealthChecks: make([]types.HealthCheck, 0, len(checks)),
+	}
+
+	for _, check := range checks {
+		_, ok := critical[check.Name]
+
+		out.HealthChecks = append(out.HealthChecks, types.HealthCheck{
+			Name:     check.Name,
+			Check:    check.Check,
+			Critical: ok,
+		})
+	}
+
+	return out
+}
+
 func NewShutdownContext() context.Context {
 	return grace.ShutdownContext(context.Background())
 }
@@ -93,6 +129,20 @@ func (e *Engine) Run() {
 	}
 }
 
+// Migrator отдаёт мигратор для ручного запуска up/down/status, например из отдельной команды сервиса
+// вместо Run. Миграции при этом берутся те же, что и при старте.
+func (e *Engine) Migrator() (*migrations.Migrator, error) {
+	var m *migrations.Migrator
+
+	if err := e.container.Invoke(func(migrator *migrations.Migrator) {
+		m = migrator
+	}); err != nil {
+		return nil, fmt.Errorf("invoke migrator: %w", err)
+	}
+
+	return m, nil
+}
+
 func (e *Engine) provide(m types.Module) {
 	for _, c := range m {
 		if err := e.container.Provide(c.CreateFunc, c.Options...); err != nil {
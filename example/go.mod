@@ -1,8 +1,8 @@
 module freya/example
 
-go 1.21.5
+go 1.23.0
 
-toolchain go1.21.12
+toolchain go1.23.4
 
 require (
 	github.com/DATA-DOG/go-sqlmock v1.5.0
@@ -18,6 +18,18 @@ type (
 		WithDebugLog      bool
 		WithServerMetrics bool
 		LogRedactor       *bishamon.Redactor
+		TLS               TLSConfig
+		GatewayPrefix     string
+		// HealthInterval период обновления статусов health для Watch, 0 - по умолчанию 5s
+		HealthInterval time.Duration
+	}
+
+	TLSConfig struct {
+		CertFile          string
+		KeyFile           string
+		ClientCAFile      string
+		RequireClientCert bool
+		ReloadInterval    time.Duration
 	}
 )
 
@@ -29,5 +41,35 @@ func NewGRPCConfig(cfg *config.Config) *Config {
 		WithReflection:    cfg.GRPC.RegisterReflectionServer,
 		WithDebugLog:      cfg.DebugLog,
 		WithServerMetrics: cfg.EnableServerMetrics,
+		GatewayPrefix:     cfg.GRPC.GatewayPrefix,
+		HealthInterval:    cfg.Health.RefreshInterval,
+		TLS: TLSConfig{
+			CertFile:          cfg.GRPC.TLS.CertFile,
+			KeyFile:           cfg.GRPC.TLS.KeyFile,
+			ClientCAFile:      cfg.GRPC.TLS.ClientCAFile,
+			RequireClientCert: cfg.GRPC.TLS.RequireClientCert,
+			ReloadInterval:    cfg.GRPC.TLS.ReloadInterval,
+		},
+	}
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// validate частично заданный TLS - ошибка конфигурации, а не молчаливый запуск без шифрования
+func (c TLSConfig) validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errPartialKeyPair
+	}
+
+	if !c.Enabled() && (c.ClientCAFile != "" || c.RequireClientCert) {
+		return errClientAuthNoCert
 	}
+
+	if c.RequireClientCert && c.ClientCAFile == "" {
+		return errClientCANotSet
+	}
+
+	return nil
 }
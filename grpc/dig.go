@@ -1,6 +1,7 @@
 package grpc
 
 import (
+	"github.com/nenormalka/freya/http"
 	"github.com/nenormalka/freya/types"
 
 	"go.uber.org/dig"
@@ -10,6 +11,8 @@ var Module = types.Module{
 	{CreateFunc: NewGRPCConfig},
 	{CreateFunc: NewGRPC},
 	{CreateFunc: Adapter},
+	{CreateFunc: NewGateway},
+	{CreateFunc: GatewayAdapter},
 }
 
 type (
@@ -24,6 +27,12 @@ type (
 
 		Server *Server
 	}
+
+	GatewayAdapterOut struct {
+		dig.Out
+
+		CustomServers []http.CustomServer `group:"custom_http_servers,flatten"`
+	}
 )
 
 func Adapter(in AdapterIn) AdapterOut {
@@ -31,3 +40,13 @@ func Adapter(in AdapterIn) AdapterOut {
 		Server: in.Server,
 	}
 }
+
+func GatewayAdapter(gateway *Gateway) GatewayAdapterOut {
+	if gateway == nil {
+		return GatewayAdapterOut{}
+	}
+
+	return GatewayAdapterOut{
+		CustomServers: []http.CustomServer{gateway},
+	}
+}
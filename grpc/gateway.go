@@ -0,0 +1,171 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	nethttp "net/http"
+	"strings"
+
+	freyahttp "github.com/nenormalka/freya/http"
+	"github.com/nenormalka/freya/metadata"
+	freyaerrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/gorilla/mux"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	gatewayServerName = "grpc-gateway"
+	featureTogglePref = "feature-toggle-"
+)
+
+var (
+	errGatewayClientCert = errors.New("grpc-gateway can not dial grpc server that requires client certificates")
+	// errGatewayPrefix на "/" gateway перехватил бы все пути, включая ручки CustomServer
+	errGatewayPrefix = errors.New("grpc-gateway prefix must not be empty")
+
+	// gatewayHeaders http заголовки, которые превращаются в ключи метаданных из пакета metadata
+	gatewayHeaders = map[string]struct{}{
+		metadata.AppInfoFieldAppVersion:        {},
+		metadata.AppInfoFieldPlatformOSVersion: {},
+		metadata.AppInfoFieldBuild:             {},
+		metadata.AppInfoFieldPlatform:          {},
+	}
+)
+
+type (
+	// GatewayRegisterFunc совпадает с сигнатурой сгенерированных RegisterXXXHandler
+	GatewayRegisterFunc func(ctx context.Context, mux *runtime.ServeMux, conn *grpc.ClientConn) error
+
+	// Gateway транслирует http/json запросы в grpc сервер движка через loopback соединение,
+	// так что запросы проходят через все интерсепторы сервера.
+	Gateway struct {
+		mux    *runtime.ServeMux
+		conn   *grpc.ClientConn
+		prefix string
+		defs   []Definition
+		logger *zap.Logger
+	}
+)
+
+// NewGateway возвращает nil, если ни у одного Definition нет GatewayRegister
+func NewGateway(p Params, cfg *Config, logger *zap.Logger) (*Gateway, error) {
+	defs := make([]Definition, 0, len(p.GRPCDefinitions))
+	for _, def := range p.GRPCDefinitions {
+		if def.GatewayRegister != nil {
+			defs = append(defs, def)
+		}
+	}
+
+	if len(defs) == 0 {
+		return nil, nil
+	}
+
+	prefix := strings.TrimSuffix(cfg.GatewayPrefix, "/")
+	if prefix == "" {
+		return nil, errGatewayPrefix
+	}
+
+	creds := insecure.NewCredentials()
+	if cfg.TLS.Enabled() {
+		if cfg.TLS.RequireClientCert {
+			return nil, errGatewayClientCert
+		}
+
+		// сертификат выписан на внешнее имя, а ходим через loopback
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+	}
+
+	conn, err := grpc.Dial(loopbackAddr(cfg.ListenAddr), grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("dial grpc server for gateway: %w", err)
+	}
+
+	return &Gateway{
+		mux: runtime.NewServeMux(
+			runtime.WithIncomingHeaderMatcher(gatewayHeaderMatcher),
+			runtime.WithErrorHandler(gatewayErrorHandler),
+		),
+		conn:   conn,
+		prefix: prefix,
+		defs:   defs,
+		logger: logger,
+	}, nil
+}
+
+func (g *Gateway) GetServerName() string {
+	return gatewayServerName
+}
+
+func (g *Gateway) StartServer(r *mux.Router) error {
+	for _, def := range g.defs {
+		g.logger.Info(fmt.Sprintf("register grpc-gateway service: `%s`", def.Description.ServiceName))
+
+		if err := def.GatewayRegister(context.Background(), g.mux, g.conn); err != nil {
+			return fmt.Errorf("register gateway for %s: %w", def.Description.ServiceName, err)
+		}
+	}
+
+	r.PathPrefix(g.prefix + "/").Handler(nethttp.StripPrefix(g.prefix, g.mux))
+
+	return nil
+}
+
+func (g *Gateway) Close() error {
+	return g.conn.Close()
+}
+
+// gatewayHeaderMatcher App-Version -> app_version и т.д., чтобы работали хелперы пакета metadata
+func gatewayHeaderMatcher(key string) (string, bool) {
+	lower := strings.ToLower(key)
+
+	if strings.HasPrefix(lower, featureTogglePref) {
+		return lower, true
+	}
+
+	if mdKey := strings.ReplaceAll(lower, "-", "_"); hasGatewayHeader(mdKey) {
+		return mdKey, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+func hasGatewayHeader(key string) bool {
+	_, ok := gatewayHeaders[key]
+	return ok
+}
+
+// gatewayErrorHandler отдаёт ошибку как problem+json, так же как и обычные http хендлеры
+func gatewayErrorHandler(
+	_ context.Context,
+	_ *runtime.ServeMux,
+	_ runtime.Marshaler,
+	w nethttp.ResponseWriter,
+	r *nethttp.Request,
+	err error,
+) {
+	freyahttp.WriteProblem(w, r, freyaerrors.FromGRPCError(status.Convert(err).Err()))
+}
+
+func loopbackAddr(listenAddr string) string {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return listenAddr
+	}
+
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "localhost"
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+var _ freyahttp.CustomServer = (*Gateway)(nil)
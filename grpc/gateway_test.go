@@ -0,0 +1,30 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func TestNewGatewayPrefix(t *testing.T) {
+	p := Params{GRPCDefinitions: []Definition{{
+		GatewayRegister: func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error {
+			return nil
+		},
+	}}}
+
+	// на корне gateway перекрыл бы ручки остальных http серверов
+	for _, prefix := range []string{"", "/"} {
+		_, err := NewGateway(p, &Config{ListenAddr: ":0", GatewayPrefix: prefix}, zap.NewNop())
+		require.ErrorIs(t, err, errGatewayPrefix)
+	}
+
+	g, err := NewGateway(p, &Config{ListenAddr: ":0", GatewayPrefix: "/api/"}, zap.NewNop())
+	require.NoError(t, err)
+	require.Equal(t, "/api", g.prefix)
+	require.NoError(t, g.Close())
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 
 	"github.com/nenormalka/freya/types"
+	"github.com/nenormalka/freya/types/errors"
 
 	"github.com/nenormalka/bishamon"
 	"github.com/prometheus/client_golang/prometheus"
@@ -13,7 +14,7 @@ import (
 	"go.uber.org/dig"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
@@ -24,6 +25,8 @@ type (
 	Definition struct {
 		Description    *grpc.ServiceDesc
 		Implementation any
+		// GatewayRegister сгенерированная функция RegisterXXXHandler, публикует сервис через grpc-gateway
+		GatewayRegister GatewayRegisterFunc
 	}
 
 	ServerOpt func(*Config)
@@ -31,15 +34,21 @@ type (
 	Params struct {
 		dig.In
 
-		GRPCDefinitions             []Definition                    `group:"grpc_impl"`
-		GRPCUnaryCustomInterceptors [][]grpc.UnaryServerInterceptor `group:"grpc_unary_interceptor"`
-		ServerOpt                   []ServerOpt                     `group:"grpc_server_opt"`
+		GRPCDefinitions              []Definition                     `group:"grpc_impl"`
+		GRPCUnaryCustomInterceptors  [][]grpc.UnaryServerInterceptor  `group:"grpc_unary_interceptor"`
+		GRPCStreamCustomInterceptors [][]grpc.StreamServerInterceptor `group:"grpc_stream_interceptor"`
+		ServerOpt                    []ServerOpt                      `group:"grpc_server_opt"`
+		HealthChecks                 []types.HealthCheck              `group:"health_checkers"`
+		ErrorClassifiers             []errors.Classifier              `group:"error_classifiers"`
 	}
 
 	Server struct {
-		cfg    *Config
-		server *grpc.Server
-		logger *zap.Logger
+		cfg      *Config
+		server   *grpc.Server
+		logger   *zap.Logger
+		reloader *certReloader
+		gateway  *Gateway
+		health   *healthServer
 	}
 )
 
@@ -48,28 +57,61 @@ func NewGRPC(
 	cfg *Config,
 	logger *zap.Logger,
 	tracer *apm.Tracer,
-) *Server {
+	probe *types.Probe,
+	gateway *Gateway,
+) (*Server, error) {
 	for _, opt := range p.ServerOpt {
 		opt(cfg)
 	}
 
+	var (
+		reloader *certReloader
+		err      error
+	)
+
+	if err = cfg.TLS.validate(); err != nil {
+		return nil, fmt.Errorf("grpc tls err: %w", err)
+	}
+
+	if cfg.TLS.Enabled() {
+		if reloader, err = newCertReloader(cfg.TLS, logger); err != nil {
+			return nil, fmt.Errorf("grpc tls err: %w", err)
+		}
+	}
+
+	for _, hc := range p.HealthChecks {
+		if err = hc.Validate(); err != nil {
+			return nil, fmt.Errorf("grpc health check err: %w", err)
+		}
+	}
+
 	if cfg.WithServerMetrics {
 		prometheus.MustRegister(types.ServerGRPCMetrics)
 	}
 
-	grpcServer := grpc.NewServer(
+	classifiers := append(errors.Classifiers(p.ErrorClassifiers), errors.DefaultClassifiers...)
+
+	opts := []grpc.ServerOption{
 		grpc.KeepaliveParams(
 			keepalive.ServerParameters{
 				Time:    cfg.KeepaliveTime,
 				Timeout: cfg.KeepaliveTimeout,
 			},
 		),
-		grpc.ChainUnaryInterceptor(interceptors(logger, tracer, p.GRPCUnaryCustomInterceptors, cfg)...),
-	)
+		grpc.ChainUnaryInterceptor(interceptors(logger, tracer, p.GRPCUnaryCustomInterceptors, classifiers, cfg)...),
+		grpc.ChainStreamInterceptor(streamInterceptors(logger, tracer, p.GRPCStreamCustomInterceptors, classifiers, cfg)...),
+	}
+
+	if reloader != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.tlsConfig())))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	hs := newHealthServer(probe, p.GRPCDefinitions, p.HealthChecks, cfg.HealthInterval)
 
 	p.GRPCDefinitions = append(p.GRPCDefinitions, Definition{
 		Description:    &grpc_health_v1.Health_ServiceDesc,
-		Implementation: health.NewServer(),
+		Implementation: hs,
 	})
 
 	for _, def := range p.GRPCDefinitions {
@@ -86,15 +128,24 @@ func NewGRPC(
 	}
 
 	return &Server{
-		cfg:    cfg,
-		server: grpcServer,
-		logger: logger,
-	}
+		cfg:      cfg,
+		server:   grpcServer,
+		logger:   logger,
+		reloader: reloader,
+		gateway:  gateway,
+		health:   hs,
+	}, nil
 }
 
 func (s *Server) Start(ctx context.Context) error {
 	s.logger.Info("GRPC server started, listening on address: ", zap.String("grpc start", s.cfg.ListenAddr))
 
+	if s.reloader != nil {
+		s.reloader.start(ctx)
+	}
+
+	s.health.start(ctx)
+
 	return types.StartServerWithWaiting(ctx, s.logger, func(errCh chan error) {
 		listener, err := net.Listen("tcp", s.cfg.ListenAddr)
 		if err != nil {
@@ -114,7 +165,17 @@ func (s *Server) Start(ctx context.Context) error {
 }
 
 func (s *Server) Stop(_ context.Context) error {
+	if s.reloader != nil {
+		s.reloader.stop()
+	}
+
+	s.health.stop()
 	s.server.GracefulStop()
+
+	if s.gateway != nil {
+		return s.gateway.Close()
+	}
+
 	return nil
 }
 
@@ -0,0 +1,236 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nenormalka/freya/types"
+
+	lilith "github.com/nenormalka/lilith/patterns"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// defaultHealthInterval как часто обновляются статусы для Watch, если HEALTH_REFRESH_INTERVAL не задан
+	defaultHealthInterval = 5 * time.Second
+)
+
+var (
+	errCheckTimeout = errors.New("max check time exceeded")
+)
+
+type (
+	// healthServer отвечает по именам проверок из группы `health_checkers`, а остальные имена сервисов
+	// отдаёт встроенному health.Server. Общий статус ("") дополнительно учитывает критичные проверки.
+	// Check выполняет проверки на запрос, а для Watch статусы обновляются в фоне через SetServingStatus.
+	healthServer struct {
+		*health.Server
+
+		probe    *types.Probe
+		services []string
+		checks   map[string]types.HealthCheck
+		critical []types.HealthCheck
+		// interval период фонового обновления, он же потолок таймаута одной проверки
+		interval   time.Duration
+		criticalOK atomic.Bool
+		cancel     context.CancelFunc
+	}
+)
+
+// newHealthServer возвращает health сервер, статусы которого следуют за probe: NOT_SERVING, пока
+// приложение запускается, SERVING после старта и снова NOT_SERVING, как только началось выключение.
+func newHealthServer(
+	probe *types.Probe,
+	defs []Definition,
+	checks []types.HealthCheck,
+	interval time.Duration,
+) *healthServer {
+	if interval <= 0 {
+		interval = defaultHealthInterval
+	}
+
+	hs := &healthServer{
+		Server:   health.NewServer(),
+		probe:    probe,
+		checks:   make(map[string]types.HealthCheck, len(checks)),
+		interval: interval,
+	}
+
+	hs.criticalOK.Store(true)
+
+	for _, check := range checks {
+		hs.checks[check.Name] = check
+
+		if check.Critical {
+			hs.critical = append(hs.critical, check)
+		}
+	}
+
+	hs.services = make([]string, 0, len(defs)+1)
+	hs.services = append(hs.services, "")
+
+	for _, def := range defs {
+		hs.services = append(hs.services, def.Description.ServiceName)
+	}
+
+	probe.OnChange(func(state types.ProbeState) {
+		switch state {
+		case types.ProbeReady:
+			hs.Resume()
+			hs.setServices()
+		case types.ProbeStopping:
+			hs.Shutdown()
+		default:
+			for _, service := range hs.services {
+				hs.SetServingStatus(service, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+			}
+		}
+	})
+
+	return hs
+}
+
+func (hs *healthServer) Check(
+	ctx context.Context,
+	req *grpc_health_v1.HealthCheckRequest,
+) (*grpc_health_v1.HealthCheckResponse, error) {
+	if check, ok := hs.checks[req.GetService()]; ok {
+		return healthResponse(hs.run(ctx, check) == nil), nil
+	}
+
+	resp, err := hs.Server.Check(ctx, req)
+	if err != nil || req.GetService() != "" || resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return resp, err
+	}
+
+	return healthResponse(hs.runCritical(ctx)), nil
+}
+
+// start обновляет статусы проверок и сервисов в фоне, чтобы подписчики Watch узнавали об отказах
+func (hs *healthServer) start(ctx context.Context) {
+	if len(hs.checks) == 0 {
+		return
+	}
+
+	ctx, hs.cancel = context.WithCancel(ctx)
+
+	lilith.TickerV2(ctx, hs.interval, func() {
+		hs.refresh(ctx)
+	})
+}
+
+func (hs *healthServer) stop() {
+	if hs.cancel != nil {
+		hs.cancel()
+	}
+}
+
+func (hs *healthServer) refresh(ctx context.Context) {
+	var (
+		wg      sync.WaitGroup
+		failed  atomic.Bool
+		serving = make([]bool, 0, len(hs.checks))
+		names   = make([]string, 0, len(hs.checks))
+	)
+
+	for name := range hs.checks {
+		names = append(names, name)
+		serving = append(serving, false)
+	}
+
+	wg.Add(len(names))
+
+	for i, name := range names {
+		go func(i int, check types.HealthCheck) {
+			defer wg.Done()
+
+			serving[i] = hs.run(ctx, check) == nil
+			if !serving[i] && check.Critical {
+				failed.Store(true)
+			}
+		}(i, hs.checks[name])
+	}
+
+	wg.Wait()
+
+	// сервис останавливается, результаты прерванных проверок ничего не говорят о зависимостях
+	if ctx.Err() != nil {
+		return
+	}
+
+	for i, name := range names {
+		hs.SetServingStatus(name, healthStatus(serving[i]))
+	}
+
+	hs.criticalOK.Store(!failed.Load())
+	hs.setServices()
+}
+
+// setServices общий статус и статусы сервисов после старта зависят от критичных проверок
+func (hs *healthServer) setServices() {
+	if !hs.probe.IsReady() {
+		return
+	}
+
+	status := healthStatus(hs.criticalOK.Load())
+	for _, service := range hs.services {
+		hs.SetServingStatus(service, status)
+	}
+}
+
+func (hs *healthServer) runCritical(ctx context.Context) bool {
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Bool
+	)
+
+	wg.Add(len(hs.critical))
+
+	for _, check := range hs.critical {
+		go func(check types.HealthCheck) {
+			defer wg.Done()
+
+			if err := hs.run(ctx, check); err != nil {
+				failed.Store(true)
+			}
+		}(check)
+	}
+
+	wg.Wait()
+
+	return !failed.Load()
+}
+
+// run выполняет проверку не дольше interval, даже если сама проверка не следит за контекстом
+func (hs *healthServer) run(ctx context.Context, check types.HealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, hs.interval)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- check.Run(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return errCheckTimeout
+	}
+}
+
+func healthStatus(serving bool) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	if serving {
+		return grpc_health_v1.HealthCheckResponse_SERVING
+	}
+
+	return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+}
+
+func healthResponse(serving bool) *grpc_health_v1.HealthCheckResponse {
+	return &grpc_health_v1.HealthCheckResponse{Status: healthStatus(serving)}
+}
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nenormalka/freya/types"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type fakeWatchStream struct {
+	grpc.ServerStream
+
+	ctx     context.Context
+	updates chan grpc_health_v1.HealthCheckResponse_ServingStatus
+}
+
+func TestHealthServerWatch(t *testing.T) {
+	var dbUp atomic.Bool
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	probe := types.NewProbe()
+	hs := newHealthServer(probe, nil, []types.HealthCheck{
+		{
+			Name:     "db",
+			Critical: true,
+			Check: func(context.Context) error {
+				if !dbUp.Load() {
+					return errors.New("connection refused")
+				}
+
+				return nil
+			},
+		},
+		{
+			// зависшая проверка, которая не следит за контекстом
+			Name: "cache",
+			Check: func(context.Context) error {
+				<-stuck
+				return nil
+			},
+		},
+	}, 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := &fakeWatchStream{ctx: ctx, updates: make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 10)}
+	go func() {
+		_ = hs.Watch(&grpc_health_v1.HealthCheckRequest{}, stream)
+	}()
+
+	probe.SetState(types.ProbeReady)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, waitStatus(t, stream))
+
+	hs.refresh(ctx)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, waitStatus(t, stream))
+	requireStatus(t, hs, "db", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	requireStatus(t, hs, "cache", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	dbUp.Store(true)
+	hs.refresh(ctx)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, waitStatus(t, stream))
+	requireStatus(t, hs, "db", grpc_health_v1.HealthCheckResponse_SERVING)
+
+	// Check тоже не ждёт зависшую проверку дольше интервала
+	resp, err := hs.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "cache"})
+	require.NoError(t, err)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, resp.GetStatus())
+
+	probe.SetState(types.ProbeStopping)
+	require.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, waitStatus(t, stream))
+}
+
+func requireStatus(t *testing.T, hs *healthServer, service string, want grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+
+	// встроенный Check отдаёт статус, выставленный через SetServingStatus
+	resp, err := hs.Server.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+	require.NoError(t, err)
+	require.Equal(t, want, resp.GetStatus())
+}
+
+func waitStatus(t *testing.T, stream *fakeWatchStream) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	select {
+	case status := <-stream.updates:
+		return status
+	case <-time.After(time.Second):
+		t.Fatal("no health update")
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN
+	}
+}
+
+func (s *fakeWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeWatchStream) Send(resp *grpc_health_v1.HealthCheckResponse) error {
+	s.updates <- resp.GetStatus()
+	return nil
+}
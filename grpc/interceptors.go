@@ -7,6 +7,7 @@ import (
 	"runtime/debug"
 	"strings"
 
+	"github.com/nenormalka/freya/logger/redact"
 	"github.com/nenormalka/freya/types"
 	"github.com/nenormalka/freya/types/errors"
 
@@ -14,7 +15,6 @@ import (
 	grpcctxtags "github.com/grpc-ecosystem/go-grpc-middleware/tags"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
 	sentry "github.com/johnbellone/grpc-middleware-sentry"
-	"github.com/nenormalka/bishamon"
 	"go.elastic.co/apm/module/apmgrpc/v2"
 	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
@@ -22,7 +22,6 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
 )
@@ -31,10 +30,31 @@ const (
 	fieldName = "grpc.details"
 )
 
+type (
+	// payloadLoggingServerStream логирует каждое сообщение стрима
+	payloadLoggingServerStream struct {
+		grpc.ServerStream
+
+		logger    *zap.Logger
+		config    *Config
+		methodFld zap.Field
+	}
+)
+
+var (
+	sentryCodesToReport = []codes.Code{
+		codes.Unknown,
+		codes.DeadlineExceeded,
+		codes.Internal,
+		codes.Unimplemented,
+	}
+)
+
 func interceptors(
 	logger *zap.Logger,
 	tracer *apm.Tracer,
 	customInterceptors [][]grpc.UnaryServerInterceptor,
+	classifiers errors.Classifiers,
 	config *Config,
 ) []grpc.UnaryServerInterceptor {
 	ints := []grpc.UnaryServerInterceptor{
@@ -42,14 +62,9 @@ func interceptors(
 		grpcctxtags.UnaryServerInterceptor(grpcctxtags.WithFieldExtractor(grpcctxtags.CodeGenRequestFieldExtractor)),
 		payloadLoggingInterceptor(logger, config),
 		logMetadataInterceptor(logger, config),
-		initSentryInterceptor([]codes.Code{
-			codes.Unknown,
-			codes.DeadlineExceeded,
-			codes.Internal,
-			codes.Unimplemented,
-		}),
+		initSentryInterceptor(sentryCodesToReport),
 		recovery.UnaryServerInterceptor(recovery.WithRecoveryHandlerContext(panicInterceptor(logger))),
-		checkErrorInterceptor(),
+		checkErrorInterceptor(logger, classifiers),
 	}
 
 	if config.WithServerMetrics {
@@ -63,6 +78,34 @@ func interceptors(
 	return ints
 }
 
+func streamInterceptors(
+	logger *zap.Logger,
+	tracer *apm.Tracer,
+	customInterceptors [][]grpc.StreamServerInterceptor,
+	classifiers errors.Classifiers,
+	config *Config,
+) []grpc.StreamServerInterceptor {
+	ints := []grpc.StreamServerInterceptor{
+		apmgrpc.NewStreamServerInterceptor(apmgrpc.WithRecovery(), apmgrpc.WithTracer(tracer)),
+		grpcctxtags.StreamServerInterceptor(grpcctxtags.WithFieldExtractor(grpcctxtags.CodeGenRequestFieldExtractor)),
+		payloadLoggingStreamInterceptor(logger, config),
+		logMetadataStreamInterceptor(logger, config),
+		initSentryStreamInterceptor(sentryCodesToReport),
+		recovery.StreamServerInterceptor(recovery.WithRecoveryHandlerContext(panicInterceptor(logger))),
+		checkErrorStreamInterceptor(logger, classifiers),
+	}
+
+	if config.WithServerMetrics {
+		ints = append(ints, types.ServerGRPCMetrics.StreamServerInterceptor())
+	}
+
+	for _, customInts := range customInterceptors {
+		ints = append(ints, customInts...)
+	}
+
+	return ints
+}
+
 func panicInterceptor(logger *zap.Logger) func(ctx context.Context, p any) (err error) {
 	return func(ctx context.Context, p any) (err error) {
 		types.GRPCPanicInc()
@@ -78,7 +121,7 @@ func panicInterceptor(logger *zap.Logger) func(ctx context.Context, p any) (err
 	}
 }
 
-func checkErrorInterceptor() grpc.UnaryServerInterceptor {
+func checkErrorInterceptor(logger *zap.Logger, classifiers errors.Classifiers) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
 	) (
@@ -86,62 +129,79 @@ func checkErrorInterceptor() grpc.UnaryServerInterceptor {
 	) {
 		resp, err = handler(ctx, req)
 		if err != nil {
-			err = errors.ErrorToGRPCError(err)
+			err = classifyError(ctx, logger, classifiers, info.FullMethod, err)
 		}
 
 		return resp, err
 	}
 }
 
+func checkErrorStreamInterceptor(logger *zap.Logger, classifiers errors.Classifiers) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return classifyError(ss.Context(), logger, classifiers, info.FullMethod, err)
+		}
+
+		return nil
+	}
+}
+
+// classifyError переводит ошибку в grpc статус. Если классификатор спрятал исходный текст от клиента,
+// исходная ошибка остаётся только в логе.
+func classifyError(ctx context.Context, logger *zap.Logger, classifiers errors.Classifiers, method string, err error) error {
+	classified := classifiers.Classify(err)
+	if classified.Error() != err.Error() {
+		logger.Warn(
+			"classified error",
+			zap.String("grpc.method", method),
+			zap.Error(err),
+			fieldWithTraceID(ctx),
+		)
+	}
+
+	return errors.ErrorToGRPCError(classified)
+}
+
 func logMetadataInterceptor(logger *zap.Logger, config *Config) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
 	) (
 		resp any, err error,
 	) {
-		md, ok := metadata.FromIncomingContext(ctx)
-
-		if !config.WithDebugLog || !ok {
-			return handler(ctx, req)
-		}
+		logMetadata(ctx, logger, config)
 
-		fields := make([]zap.Field, 0, len(md)+1)
-		for key, values := range md {
-			value := ""
-			if len(values) != 0 {
-				value = values[0]
-			}
-
-			fields = append(fields, zap.String(key, value))
-		}
-
-		fields = append(fields, fieldWithTraceID(ctx))
+		return handler(ctx, req)
+	}
+}
 
-		logger.Info("metadata", fields...)
+func logMetadataStreamInterceptor(logger *zap.Logger, config *Config) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		logMetadata(ss.Context(), logger, config)
 
-		return handler(ctx, req)
+		return handler(srv, ss)
 	}
 }
 
-func marshalPayload(msg any, redactor *bishamon.Redactor) (result string) {
-	p, ok := msg.(proto.Message)
-	if !ok {
-		return "msg is not proto.Message"
+func logMetadata(ctx context.Context, logger *zap.Logger, config *Config) {
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	if !config.WithDebugLog || !ok {
+		return
 	}
 
-	redactedMessage := proto.Clone(p)
-	if redactor != nil {
-		if err := redactor.Redact(redactedMessage); err != nil {
-			return "redact: " + err.Error()
+	fields := make([]zap.Field, 0, len(md)+1)
+	for key, values := range md {
+		value := ""
+		if len(values) != 0 {
+			value = values[0]
 		}
-	}
 
-	bytes, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(redactedMessage)
-	if err != nil {
-		return "marshal: " + err.Error()
+		fields = append(fields, zap.String(key, value))
 	}
 
-	return string(bytes)
+	fields = append(fields, fieldWithTraceID(ctx))
+
+	logger.Info("metadata", fields...)
 }
 
 func payloadLoggingInterceptor(logger *zap.Logger, config *Config) grpc.UnaryServerInterceptor {
@@ -156,7 +216,7 @@ func payloadLoggingInterceptor(logger *zap.Logger, config *Config) grpc.UnarySer
 		apiLogger.Info(
 			fmt.Sprintf("unary call %s", info.FullMethod),
 			methodFld,
-			zap.String("grpc.payload", marshalPayload(req, config.LogRedactor)),
+			zap.String("grpc.payload", redact.Proto(req, config.LogRedactor)),
 			fieldWithTraceID(ctx),
 		)
 
@@ -168,7 +228,7 @@ func payloadLoggingInterceptor(logger *zap.Logger, config *Config) grpc.UnarySer
 		responseField := zap.Skip()
 
 		if config.WithDebugLog || zap.WarnLevel.Enabled(level) {
-			responseField = zap.String("grpc.payload", marshalPayload(resp, config.LogRedactor))
+			responseField = zap.String("grpc.payload", redact.Proto(resp, config.LogRedactor))
 		}
 
 		apiLogger.Log(
@@ -186,8 +246,83 @@ func payloadLoggingInterceptor(logger *zap.Logger, config *Config) grpc.UnarySer
 	}
 }
 
+func payloadLoggingStreamInterceptor(logger *zap.Logger, config *Config) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		apiLogger := logger.Named("api")
+		methodFld := zap.String("grpc.method", path.Base(info.FullMethod))
+
+		apiLogger.Info(
+			fmt.Sprintf("stream call %s", info.FullMethod),
+			methodFld,
+			zap.Bool("grpc.client_stream", info.IsClientStream),
+			zap.Bool("grpc.server_stream", info.IsServerStream),
+			fieldWithTraceID(ctx),
+		)
+
+		err := handler(srv, &payloadLoggingServerStream{
+			ServerStream: ss,
+			logger:       apiLogger,
+			config:       config,
+			methodFld:    methodFld,
+		})
+
+		code := status.Code(err)
+
+		apiLogger.Log(
+			grpczap.DefaultCodeToLevel(code),
+			fmt.Sprintf("finished stream call with code %s", code.String()),
+			methodFld,
+			zap.String("grpc.code", code.String()),
+			fieldWithGRPCDetailsError(err),
+			zap.Error(err),
+			fieldWithTraceID(ctx),
+		)
+
+		return err
+	}
+}
+
+// RecvMsg логирует каждое входящее сообщение, как unary логирует запрос
+func (s *payloadLoggingServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	s.logger.Info(
+		"stream recv",
+		s.methodFld,
+		zap.String("grpc.payload", redact.Proto(m, s.config.LogRedactor)),
+		fieldWithTraceID(s.Context()),
+	)
+
+	return nil
+}
+
+// SendMsg логирует исходящие сообщения только с включённым DebugLog, как ответы unary
+func (s *payloadLoggingServerStream) SendMsg(m any) error {
+	if s.config.WithDebugLog {
+		s.logger.Info(
+			"stream send",
+			s.methodFld,
+			zap.String("grpc.payload", redact.Proto(m, s.config.LogRedactor)),
+			fieldWithTraceID(s.Context()),
+		)
+	}
+
+	return s.ServerStream.SendMsg(m)
+}
+
 func initSentryInterceptor(codesToReport []codes.Code) grpc.UnaryServerInterceptor {
-	return sentry.UnaryServerInterceptor(
+	return sentry.UnaryServerInterceptor(sentryOptions(codesToReport)...)
+}
+
+func initSentryStreamInterceptor(codesToReport []codes.Code) grpc.StreamServerInterceptor {
+	return sentry.StreamServerInterceptor(sentryOptions(codesToReport)...)
+}
+
+func sentryOptions(codesToReport []codes.Code) []sentry.Option {
+	return []sentry.Option{
 		sentry.WithReportOn(func(err error) bool {
 			currentCode := status.Code(err)
 			for _, codeToReport := range codesToReport {
@@ -199,7 +334,7 @@ func initSentryInterceptor(codesToReport []codes.Code) grpc.UnaryServerIntercept
 			return false
 		}),
 		sentry.WithRepanicOption(true),
-	)
+	}
 }
 
 func fieldWithTraceID(ctx context.Context) zap.Field {
@@ -0,0 +1,230 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	lilith "github.com/nenormalka/lilith/patterns"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+const http2Proto = "h2"
+
+var (
+	errClientCANotSet   = errors.New("client certificate is required, but client CA file is not set")
+	errNoCACerts        = errors.New("no certificates found in client CA file")
+	errPartialKeyPair   = errors.New("both tls cert file and key file must be set")
+	errClientAuthNoCert = errors.New("client CA file or client certificate requirement is set, but tls cert is not")
+)
+
+type (
+	// PeerIdentity данные проверенного клиентского сертификата
+	PeerIdentity struct {
+		CommonName   string
+		Organization []string
+		DNSNames     []string
+		URIs         []string
+		Certificate  *x509.Certificate
+	}
+
+	// certReloader отдаёт сертификаты через GetConfigForClient и перечитывает файлы, когда они меняются на диске
+	certReloader struct {
+		cfg    TLSConfig
+		logger *zap.Logger
+
+		mu       sync.RWMutex
+		config   *tls.Config
+		modTimes map[string]time.Time
+
+		cancel context.CancelFunc
+	}
+)
+
+func newCertReloader(cfg TLSConfig, logger *zap.Logger) (*certReloader, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &certReloader{
+		cfg:    cfg,
+		logger: logger,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{http2Proto},
+		GetConfigForClient: r.getConfigForClient,
+	}
+}
+
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.config, nil
+}
+
+// serverConfig конфиг из GetConfigForClient целиком заменяет базовый, поэтому ALPN h2 задаётся и здесь,
+// иначе клиенты gRPC не договорятся о протоколе
+func (r *certReloader) serverConfig(cert *tls.Certificate, clientCAs *x509.CertPool) *tls.Config {
+	cfg := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{http2Proto},
+		Certificates: []tls.Certificate{*cert},
+		ClientAuth:   tls.NoClientCert,
+	}
+
+	if clientCAs != nil {
+		cfg.ClientCAs = clientCAs
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+
+		if r.cfg.RequireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return cfg
+}
+
+func (r *certReloader) start(ctx context.Context) {
+	if r.cfg.ReloadInterval <= 0 {
+		return
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	lilith.TickerV2(ctx, r.cfg.ReloadInterval, func() {
+		if !r.changed() {
+			return
+		}
+
+		if err := r.load(); err != nil {
+			r.logger.Error("failed to reload grpc tls certificates", zap.Error(err))
+			return
+		}
+
+		r.logger.Info("grpc tls certificates reloaded")
+	})
+}
+
+func (r *certReloader) stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+func (r *certReloader) load() error {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("read client CA file: %w", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errNoCACerts
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = r.serverConfig(&cert, clientCAs)
+	r.modTimes = modTimes
+
+	return nil
+}
+
+func (r *certReloader) changed() bool {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		r.logger.Error("failed to stat grpc tls files", zap.Error(err))
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for file, modTime := range modTimes {
+		if !modTime.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *certReloader) statFiles() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, 3)
+
+	for _, file := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("stat %s: %w", file, err)
+		}
+
+		modTimes[file] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// PeerIdentityFromContext возвращает данные клиентского сертификата, прошедшего проверку по ClientCAFile.
+// Годится для интерсепторов и хендлеров, false - если соединение без mTLS.
+func PeerIdentityFromContext(ctx context.Context) (PeerIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return PeerIdentity{}, false
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return PeerIdentity{}, false
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return PeerIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		URIs:         uris,
+		Certificate:  cert,
+	}, true
+}
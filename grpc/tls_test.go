@@ -0,0 +1,226 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newTestCert(t, "ca", nil)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.pem)
+	writeServerCert(t, dir, newTestCert(t, "server-1", ca))
+
+	r, err := newCertReloader(TLSConfig{
+		CertFile:          filepath.Join(dir, "cert.pem"),
+		KeyFile:           filepath.Join(dir, "key.pem"),
+		ClientCAFile:      filepath.Join(dir, "ca.pem"),
+		RequireClientCert: true,
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	cfg, err := r.getConfigForClient(nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"h2"}, cfg.NextProtos)
+	require.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	require.Equal(t, "server-1", leafCN(t, cfg))
+	require.False(t, r.changed())
+
+	client := newTestCert(t, "client", ca)
+	state := handshake(t, r.tlsConfig(), client, ca)
+	require.Equal(t, "h2", state.NegotiatedProtocol)
+	require.Equal(t, "server-1", state.PeerCertificates[0].Subject.CommonName)
+
+	// новый сертификат подхватывается после перечитывания файлов
+	writeServerCert(t, dir, newTestCert(t, "server-2", ca))
+	future := time.Now().Add(time.Minute)
+	for _, file := range []string{"cert.pem", "key.pem"} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, file), future, future))
+	}
+
+	require.True(t, r.changed())
+	require.NoError(t, r.load())
+	require.False(t, r.changed())
+
+	state = handshake(t, r.tlsConfig(), client, ca)
+	require.Equal(t, "h2", state.NegotiatedProtocol)
+	require.Equal(t, "server-2", state.PeerCertificates[0].Subject.CommonName)
+
+	_, err = newCertReloader(TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", RequireClientCert: true}, zap.NewNop())
+	require.ErrorIs(t, err, errClientCANotSet)
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TLSConfig
+		err  error
+	}{
+		{name: "disabled"},
+		{name: "cert and key", cfg: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}},
+		{name: "only cert", cfg: TLSConfig{CertFile: "cert.pem"}, err: errPartialKeyPair},
+		{name: "only key", cfg: TLSConfig{KeyFile: "key.pem"}, err: errPartialKeyPair},
+		{name: "client CA without cert", cfg: TLSConfig{ClientCAFile: "ca.pem"}, err: errClientAuthNoCert},
+		{name: "require client cert without cert", cfg: TLSConfig{RequireClientCert: true}, err: errClientAuthNoCert},
+		{
+			name: "require client cert without CA",
+			cfg:  TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", RequireClientCert: true},
+			err:  errClientCANotSet,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, tt.cfg.validate(), tt.err)
+		})
+	}
+}
+
+func TestPeerIdentityFromContext(t *testing.T) {
+	_, ok := PeerIdentityFromContext(context.Background())
+	require.False(t, ok)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	_, ok = PeerIdentityFromContext(ctx)
+	require.False(t, ok)
+
+	cert := newTestCert(t, "client", nil).cert
+	cert.Subject.Organization = []string{"freya"}
+	cert.DNSNames = []string{"client.local"}
+	cert.URIs = []*url.URL{{Scheme: "spiffe", Host: "freya", Path: "/client"}}
+
+	ctx = peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{
+		State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+	}})
+
+	identity, ok := PeerIdentityFromContext(ctx)
+	require.True(t, ok)
+	require.Equal(t, PeerIdentity{
+		CommonName:   "client",
+		Organization: []string{"freya"},
+		DNSNames:     []string{"client.local"},
+		URIs:         []string{"spiffe://freya/client"},
+		Certificate:  cert,
+	}, identity)
+}
+
+func handshake(t *testing.T, serverCfg *tls.Config, client, ca *testCert) tls.ConnectionState {
+	t.Helper()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	srv := tls.Server(serverConn, serverCfg)
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- srv.Handshake()
+	}()
+
+	cli := tls.Client(clientConn, &tls.Config{
+		ServerName: "localhost",
+		RootCAs:    roots,
+		NextProtos: []string{"h2"},
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{client.cert.Raw},
+			PrivateKey:  client.key,
+		}},
+	})
+
+	require.NoError(t, cli.Handshake())
+	require.NoError(t, <-errCh)
+
+	return cli.ConnectionState()
+}
+
+func newTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	issuer, signer := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		issuer, signer = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+func writeServerCert(t *testing.T, dir string, c *testCert) {
+	t.Helper()
+
+	key, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+
+	writeFile(t, filepath.Join(dir, "cert.pem"), c.pem)
+	writeFile(t, filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}))
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(name, data, 0o600))
+}
+
+func leafCN(t *testing.T, cfg *tls.Config) string {
+	t.Helper()
+
+	require.Len(t, cfg.Certificates, 1)
+
+	leaf, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	require.NoError(t, err)
+
+	return leaf.Subject.CommonName
+}
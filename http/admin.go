@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/nenormalka/freya/types"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	bearerPrefix = "Bearer "
+)
+
+type (
+	// Admin отдельный сервер для pprof, метрик, health и админских ручек, чтобы они не торчали наружу
+	// вместе с публичным api. Поднимается, если задан HTTP_ADMIN_LISTEN_ADDR.
+	Admin struct {
+		server *http.Server
+		logger *zap.Logger
+		cfg    AdminConfig
+		health *health
+	}
+)
+
+func NewAdmin(
+	config Config,
+	logger *zap.Logger,
+	adminServerList AdminServerList,
+	probe *types.Probe,
+	healthCheckList HealthCheckList,
+) (*Admin, error) {
+	if !config.Admin.Enabled() {
+		return nil, nil
+	}
+
+	r := mux.NewRouter()
+	r.Use(adminAuthMiddleware(config.Admin))
+
+	healthHandler, err := operationalRoutes(r, config, logger, probe, healthCheckList, adminServerList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Admin{
+		server: &http.Server{
+			Handler:     r,
+			Addr:        config.Admin.ListenAddr,
+			IdleTimeout: config.KeepaliveTime + config.KeepaliveTimeout,
+		},
+		logger: logger,
+		cfg:    config.Admin,
+		health: healthHandler,
+	}, nil
+}
+
+func (a *Admin) Start(ctx context.Context) error {
+	a.logger.Info("HTTP admin server started, listening on address: ", zap.String("http admin start", a.cfg.ListenAddr))
+
+	a.health.start(ctx)
+
+	return types.StartServerWithWaiting(ctx, a.logger, func(errCh chan error) {
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.logger.Error("http admin server err", zap.Error(err))
+			if errCh != nil {
+				errCh <- err
+			}
+		}
+	})
+}
+
+func (a *Admin) Stop(ctx context.Context) error {
+	a.health.stop()
+
+	return a.server.Shutdown(ctx)
+}
+
+// adminAuthMiddleware пускает по basic auth или bearer токену, если они заданы. Health ручки открыты всегда,
+// иначе пробы k8s придётся учить авторизации.
+func adminAuthMiddleware(cfg AdminConfig) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if cfg.User == "" && cfg.Token == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") || cfg.authorized(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.User != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="admin"`)
+			}
+
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+func (c AdminConfig) authorized(r *http.Request) bool {
+	if c.Token != "" {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, bearerPrefix) &&
+			secureEqual(strings.TrimPrefix(auth, bearerPrefix), c.Token) {
+			return true
+		}
+	}
+
+	if c.User != "" {
+		if user, password, ok := r.BasicAuth(); ok &&
+			secureEqual(user, c.User) && secureEqual(password, c.Password) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"strings"
 	"time"
 
 	"github.com/nenormalka/freya/config"
@@ -12,6 +13,24 @@ type Config struct {
 	KeepaliveTime    time.Duration
 	KeepaliveTimeout time.Duration
 	ReleaseID        string
+
+	HealthRefreshInterval time.Duration
+	HealthStaleAfter      time.Duration
+
+	WithDebugLog      bool
+	WithServerMetrics bool
+	// RedactHeaders и RedactKeys в нижнем регистре, сравниваются без учёта регистра
+	RedactHeaders []string
+	RedactKeys    []string
+
+	Admin AdminConfig
+}
+
+type AdminConfig struct {
+	ListenAddr string
+	User       string
+	Password   string
+	Token      string
 }
 
 func NewHTTPConfig(cfg *config.Config) Config {
@@ -20,5 +39,35 @@ func NewHTTPConfig(cfg *config.Config) Config {
 		KeepaliveTime:    cfg.HTTP.KeepaliveTime,
 		KeepaliveTimeout: cfg.HTTP.KeepaliveTimeout,
 		ReleaseID:        cfg.ReleaseID,
+
+		HealthRefreshInterval: cfg.Health.RefreshInterval,
+		HealthStaleAfter:      cfg.Health.StaleAfter,
+
+		WithDebugLog:      cfg.DebugLog,
+		WithServerMetrics: cfg.EnableServerMetrics,
+		RedactHeaders:     splitLower(cfg.HTTP.LogRedactHeaders),
+		RedactKeys:        splitLower(cfg.HTTP.LogRedactKeys),
+
+		Admin: AdminConfig{
+			ListenAddr: types.CheckAddr(cfg.HTTP.Admin.ListenAddr),
+			User:       cfg.HTTP.Admin.User,
+			Password:   cfg.HTTP.Admin.Password,
+			Token:      cfg.HTTP.Admin.Token,
+		},
 	}
 }
+
+func (c AdminConfig) Enabled() bool {
+	return c.ListenAddr != ""
+}
+
+func splitLower(s string) []string {
+	res := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"github.com/nenormalka/freya/types"
 
+	"github.com/gorilla/mux"
 	"go.uber.org/dig"
 )
 
@@ -10,6 +11,9 @@ var Module = types.Module{
 	{CreateFunc: NewHTTPConfig},
 	{CreateFunc: NewHTTP},
 	{CreateFunc: Adapter},
+	{CreateFunc: NewProblems},
+	{CreateFunc: NewAdmin},
+	{CreateFunc: AdminAdapter},
 }
 
 type (
@@ -25,11 +29,37 @@ type (
 		Server *Server
 	}
 
+	HealthCheckList struct {
+		dig.In
+
+		HealthChecks []types.HealthCheck `group:"health_checkers"`
+	}
+
 	CustomServerList struct {
 		dig.In
 
 		CustomServers []CustomServer `group:"custom_http_servers"`
 	}
+
+	// AdminServerList служебные ручки, которые живут рядом с pprof и метриками: на админ сервере, если он
+	// включён, иначе на основном без middleware
+	AdminServerList struct {
+		dig.In
+
+		AdminServers []CustomServer `group:"admin_http_servers"`
+	}
+
+	AdminAdapterOut struct {
+		dig.Out
+
+		Servers []types.Runnable `group:"servers,flatten"`
+	}
+
+	MiddlewareList struct {
+		dig.In
+
+		Middlewares [][]mux.MiddlewareFunc `group:"http_middleware"`
+	}
 )
 
 func Adapter(in AdapterIn) AdapterOut {
@@ -37,3 +67,13 @@ func Adapter(in AdapterIn) AdapterOut {
 		Server: in.Server,
 	}
 }
+
+func AdminAdapter(admin *Admin) AdminAdapterOut {
+	if admin == nil {
+		return AdminAdapterOut{}
+	}
+
+	return AdminAdapterOut{
+		Servers: []types.Runnable{admin},
+	}
+}
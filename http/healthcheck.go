@@ -10,11 +10,23 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/nenormalka/freya/types"
+
+	lilith "github.com/nenormalka/lilith/patterns"
 )
 
 const (
 	healthCheckStatusPass healthCheckStatus = "pass"
 	healthCheckStatusFail healthCheckStatus = "fail"
+
+	readinessCheckerName = "readiness"
+)
+
+var (
+	errNotReady   = errors.New("application is not ready")
+	errNoResult   = errors.New("no check result yet")
+	errStaleCheck = errors.New("check result is stale")
 )
 
 type (
@@ -29,9 +41,12 @@ type (
 	}
 
 	checkResponse struct {
-		Status healthCheckStatus `json:"status"`
-		Output string            `json:"output,omitempty"`
-		Time   string            `json:"time"`
+		Status   healthCheckStatus `json:"status"`
+		Output   string            `json:"output,omitempty"`
+		Time     string            `json:"time"`
+		Duration string            `json:"duration,omitempty"`
+		Age      string            `json:"age,omitempty"`
+		Stale    bool              `json:"stale,omitempty"`
 	}
 
 	health struct {
@@ -39,6 +54,30 @@ type (
 		observers map[string]Checker
 		timeout   time.Duration
 		releaseID string
+
+		// refresh интервал фонового обновления, 0 - проверки выполняются на каждый запрос
+		refresh    time.Duration
+		staleAfter time.Duration
+		// intervals собственные интервалы обновления проверок
+		intervals map[string]time.Duration
+		// live проверки, которые всегда выполняются на запрос, даже при фоновом обновлении
+		live map[string]struct{}
+
+		// results общие для хендлеров с одним набором проверок, обновляет их только владелец
+		results *healthResults
+		shared  bool
+		cancel  context.CancelFunc
+	}
+
+	healthResults struct {
+		mu      sync.RWMutex
+		results map[string]checkResult
+	}
+
+	checkResult struct {
+		err       error
+		checkedAt time.Time
+		duration  time.Duration
 	}
 
 	// Checker checks the status of the dependency and returns error.
@@ -65,10 +104,17 @@ func (c CheckerFunc) Check(ctx context.Context) error {
 
 // Handler returns an http.Handler
 func Handler(opts ...Option) http.Handler {
+	return newHealth(opts...)
+}
+
+func newHealth(opts ...Option) *health {
 	h := &health{
 		checkers:  make(map[string]Checker),
 		observers: make(map[string]Checker),
 		timeout:   30 * time.Second,
+		intervals: make(map[string]time.Duration),
+		live:      make(map[string]struct{}),
+		results:   &healthResults{results: make(map[string]checkResult)},
 	}
 	for _, opt := range opts {
 		opt(h)
@@ -95,6 +141,61 @@ func WithObserver(name string, s Checker) Option {
 	}
 }
 
+// WithHealthCheck adds a check registered through the `health_checkers` group as a checker or an observer
+// depending on its Critical flag.
+func WithHealthCheck(hc types.HealthCheck) Option {
+	return func(h *health) {
+		if hc.Interval > 0 {
+			h.intervals[hc.Name] = hc.Interval
+		}
+
+		if hc.Critical {
+			WithChecker(hc.Name, CheckerFunc(hc.Run))(h)
+			return
+		}
+
+		WithObserver(hc.Name, CheckerFunc(hc.Run))(h)
+	}
+}
+
+// WithBackgroundRefresh runs checkers in the background every interval (or the check's own interval)
+// instead of on every request. The handler serves the last results, a result older than staleAfter
+// is marked as stale and fails the status for checkers. Refresh runs between start and stop.
+func WithBackgroundRefresh(interval, staleAfter time.Duration) Option {
+	return func(h *health) {
+		h.refresh = interval
+		h.staleAfter = staleAfter
+	}
+}
+
+// withChecksOf берёт проверки src вместе с результатами их фонового обновления, сами проверки
+// при этом выполняет только src. Так /health и /health/ready не гоняют одни и те же проверки дважды.
+func withChecksOf(src *health) Option {
+	return func(h *health) {
+		for key, checker := range src.checkers {
+			h.checkers[key] = checker
+		}
+
+		for key, observer := range src.observers {
+			h.observers[key] = observer
+		}
+
+		for key, interval := range src.intervals {
+			h.intervals[key] = interval
+		}
+
+		for key := range src.live {
+			h.live[key] = struct{}{}
+		}
+
+		h.timeout = src.timeout
+		h.refresh = src.refresh
+		h.staleAfter = src.staleAfter
+		h.results = src.results
+		h.shared = true
+	}
+}
+
 // WithTimeout configures the global timeout for all individual checkers.
 func WithTimeout(timeout time.Duration) Option {
 	return func(h *health) {
@@ -108,6 +209,21 @@ func WithReleaseID(releaseID string) Option {
 	}
 }
 
+// WithReadiness adds a checker that fails until the application lifecycle marks probe as ready.
+func WithReadiness(probe *types.Probe) Option {
+	return func(h *health) {
+		h.live[readinessCheckerName] = struct{}{}
+
+		WithChecker(readinessCheckerName, CheckerFunc(func(context.Context) error {
+			if state := probe.State(); state != types.ProbeReady {
+				return fmt.Errorf("%w: %s", errNotReady, state)
+			}
+
+			return nil
+		}))(h)
+	}
+}
+
 func ReadReleaseIDFromPath(path string) (string, error) {
 	payload, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -140,9 +256,9 @@ func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	for key, checker := range h.checkers {
 		go func(key string, checker Checker) {
-			err := checker.Check(ctx)
+			res, err := h.result(ctx, key, checker)
 			mutex.Lock()
-			checks[key] = []checkResponse{checkerResponseFromError(err)}
+			checks[key] = []checkResponse{res}
 			if err != nil {
 				status = healthCheckStatusFail
 				output = fmt.Sprintf("%s:%v", key, err)
@@ -153,13 +269,9 @@ func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	for key, observer := range h.observers {
 		go func(key string, observer Checker) {
-			err := observer.Check(ctx)
+			res, _ := h.result(ctx, key, observer)
 			mutex.Lock()
-			checks[key] = []checkResponse{checkerResponseFromError(err)}
-			if err != nil {
-				status = healthCheckStatusFail
-				output = fmt.Sprintf("%s: %v", key, err)
-			}
+			checks[key] = []checkResponse{res}
 			mutex.Unlock()
 			wg.Done()
 		}(key, observer)
@@ -177,6 +289,121 @@ func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// result выполняет проверку или, при фоновом обновлении, берёт её последний результат.
+func (h *health) result(ctx context.Context, key string, checker Checker) (checkResponse, error) {
+	if !h.cached(key) {
+		res := runCheck(ctx, checker)
+		return checkerResponseFromResult(res), res.err
+	}
+
+	h.results.mu.RLock()
+	res, ok := h.results.results[key]
+	h.results.mu.RUnlock()
+
+	if !ok {
+		return checkResponse{
+			Status: healthCheckStatusFail,
+			Output: errNoResult.Error(),
+			Time:   time.Now().UTC().String(),
+		}, errNoResult
+	}
+
+	resp := checkerResponseFromResult(res)
+	age := time.Since(res.checkedAt)
+	resp.Age = age.String()
+
+	// протухший успешный результат - отказ, в ответе видно почему
+	if staleAfter := h.staleAfterFor(key); staleAfter > 0 && age > staleAfter {
+		resp.Stale = true
+		if res.err == nil {
+			res.err = errStaleCheck
+			resp.Status = healthCheckStatusFail
+			resp.Output = errStaleCheck.Error()
+		}
+	}
+
+	return resp, res.err
+}
+
+func (h *health) cached(key string) bool {
+	if h.refresh <= 0 {
+		return false
+	}
+
+	_, ok := h.live[key]
+	return !ok
+}
+
+func (h *health) interval(key string) time.Duration {
+	if i, ok := h.intervals[key]; ok {
+		return i
+	}
+
+	return h.refresh
+}
+
+// staleAfterFor результат не считается протухшим раньше двух интервалов обновления проверки,
+// иначе при интервале больше staleAfter проверка протухала бы между обновлениями
+func (h *health) staleAfterFor(key string) time.Duration {
+	if h.staleAfter <= 0 {
+		return 0
+	}
+
+	return max(h.staleAfter, 2*h.interval(key))
+}
+
+// start запускает фоновое обновление проверок, если оно включено.
+func (h *health) start(ctx context.Context) {
+	if h == nil || h.refresh <= 0 || h.shared {
+		return
+	}
+
+	ctx, h.cancel = context.WithCancel(ctx)
+
+	for _, m := range []map[string]Checker{h.checkers, h.observers} {
+		for key, checker := range m {
+			if !h.cached(key) {
+				continue
+			}
+
+			key, checker := key, checker
+			lilith.TickerV2(ctx, h.interval(key), func() {
+				checkCtx, cancel := ctx, func() {}
+				if h.timeout > 0 {
+					checkCtx, cancel = context.WithTimeout(ctx, h.timeout)
+				}
+				defer cancel()
+
+				res := runCheck(checkCtx, checker)
+				if ctx.Err() != nil {
+					return
+				}
+
+				h.results.mu.Lock()
+				h.results.results[key] = res
+				h.results.mu.Unlock()
+			})
+		}
+	}
+}
+
+func (h *health) stop() {
+	if h != nil && h.cancel != nil {
+		h.cancel()
+	}
+}
+
+func runCheck(ctx context.Context, checker Checker) checkResult {
+	startedAt := time.Now()
+	err := checker.Check(ctx)
+
+	return checkResult{
+		err:       err,
+		checkedAt: time.Now(),
+		duration:  time.Since(startedAt),
+	}
+}
+
 func (t *timeoutChecker) Check(ctx context.Context) error {
 	checkerChan := make(chan error)
 	go func() {
@@ -197,14 +424,15 @@ func httpStatusCodeFromHealthCheckStatus(s healthCheckStatus) int {
 	return http.StatusServiceUnavailable
 }
 
-func checkerResponseFromError(err error) checkResponse {
-	res := checkResponse{
-		Status: healthCheckStatusPass,
-		Time:   time.Now().UTC().String(),
+func checkerResponseFromResult(res checkResult) checkResponse {
+	resp := checkResponse{
+		Status:   healthCheckStatusPass,
+		Time:     res.checkedAt.UTC().String(),
+		Duration: res.duration.String(),
 	}
-	if err != nil {
-		res.Status = healthCheckStatusFail
-		res.Output = err.Error()
+	if res.err != nil {
+		resp.Status = healthCheckStatusFail
+		resp.Output = res.err.Error()
 	}
-	return res
+	return resp
 }
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nenormalka/freya/types"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHealthSharedChecks(t *testing.T) {
+	var calls atomic.Int32
+
+	healthHandler := newHealth(
+		WithBackgroundRefresh(time.Hour, time.Minute),
+		WithChecker("db", CheckerFunc(func(context.Context) error {
+			calls.Add(1)
+			return nil
+		})),
+	)
+
+	probe := types.NewProbe()
+	readyHandler := newHealth(withChecksOf(healthHandler), WithReadiness(probe))
+
+	// пока фоновое обновление не прошло, результата нет
+	require.Equal(t, http.StatusServiceUnavailable, serveHealth(t, healthHandler).code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	healthHandler.start(ctx)
+	readyHandler.start(ctx)
+
+	require.Eventually(t, func() bool {
+		return serveHealth(t, healthHandler).code == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+
+	// ready читает те же результаты, readiness проверяется на каждый запрос
+	res := serveHealth(t, readyHandler)
+	require.Equal(t, http.StatusServiceUnavailable, res.code)
+	require.Equal(t, healthCheckStatusPass, res.body.Checks["db"][0].Status)
+	require.Equal(t, healthCheckStatusFail, res.body.Checks[readinessCheckerName][0].Status)
+
+	probe.SetState(types.ProbeReady)
+	require.Equal(t, http.StatusOK, serveHealth(t, readyHandler).code)
+
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestHealthStaleAfter(t *testing.T) {
+	h := newHealth(
+		WithBackgroundRefresh(time.Minute, 30*time.Second),
+		WithHealthCheck(types.HealthCheck{Name: "slow", Interval: time.Hour, Critical: true, Check: func(context.Context) error {
+			return nil
+		}}),
+		WithChecker("fast", CheckerFunc(func(context.Context) error { return nil })),
+	)
+
+	// протухание не раньше двух интервалов обновления проверки
+	require.Equal(t, 2*time.Hour, h.staleAfterFor("slow"))
+	require.Equal(t, 2*time.Minute, h.staleAfterFor("fast"))
+
+	h.results.results["fast"] = checkResult{checkedAt: time.Now().Add(-3 * time.Minute)}
+	h.results.results["slow"] = checkResult{checkedAt: time.Now().Add(-time.Hour)}
+
+	res := serveHealth(t, h)
+	require.Equal(t, http.StatusServiceUnavailable, res.code)
+	require.True(t, res.body.Checks["fast"][0].Stale)
+	require.Equal(t, healthCheckStatusFail, res.body.Checks["fast"][0].Status)
+	require.Equal(t, errStaleCheck.Error(), res.body.Checks["fast"][0].Output)
+	require.Equal(t, "fast:"+errStaleCheck.Error(), res.body.Output)
+	require.False(t, res.body.Checks["slow"][0].Stale)
+	require.Equal(t, healthCheckStatusPass, res.body.Checks["slow"][0].Status)
+}
+
+type healthResponse struct {
+	code int
+	body response
+}
+
+func serveHealth(t *testing.T, h http.Handler) healthResponse {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var body response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+
+	return healthResponse{code: rec.Code, body: body}
+}
+
+func TestHealthObserver(t *testing.T) {
+	failing := CheckerFunc(func(context.Context) error { return errors.New("down") })
+	passing := CheckerFunc(func(context.Context) error { return nil })
+
+	// упавший observer виден в ответе, но общий статус не роняет
+	res := serveHealth(t, newHealth(WithChecker("db", passing), WithObserver("kafka", failing)))
+	require.Equal(t, http.StatusOK, res.code)
+	require.Equal(t, healthCheckStatusPass, res.body.Status)
+	require.Equal(t, healthCheckStatusFail, res.body.Checks["kafka"][0].Status)
+	require.Equal(t, "down", res.body.Checks["kafka"][0].Output)
+
+	res = serveHealth(t, newHealth(WithChecker("db", failing), WithObserver("kafka", passing)))
+	require.Equal(t, http.StatusServiceUnavailable, res.code)
+	require.Equal(t, healthCheckStatusFail, res.body.Status)
+	require.Equal(t, "db:down", res.body.Output)
+
+	// критичность проверок из группы health_checkers задаёт Critical
+	res = serveHealth(t, newHealth(
+		WithHealthCheck(types.HealthCheck{Name: "db.sqlx.master", Critical: true, Check: failing}),
+		WithHealthCheck(types.HealthCheck{Name: "db.pgx.master", Check: passing}),
+	))
+	require.Equal(t, http.StatusServiceUnavailable, res.code)
+	require.Len(t, res.body.Checks, 2)
+}
+
+func TestHealthCheckWithoutFunc(t *testing.T) {
+	_, err := operationalRoutes(mux.NewRouter(), Config{}, zap.NewNop(), types.NewProbe(), HealthCheckList{
+		HealthChecks: []types.HealthCheck{{Name: "db"}},
+	}, AdminServerList{})
+	require.ErrorIs(t, err, types.ErrHealthCheckNoFunc)
+}
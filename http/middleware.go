@@ -0,0 +1,301 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/nenormalka/freya/logger/redact"
+	"github.com/nenormalka/freya/types"
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	sentryhttp "github.com/getsentry/sentry-go/http"
+	"github.com/gorilla/mux"
+	"go.elastic.co/apm/module/apmhttp/v2"
+	"go.elastic.co/apm/v2"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	unknownRoute = "unknown"
+)
+
+type (
+	// responseWriter запоминает статус и размер ответа, с DebugLog ещё и само тело
+	responseWriter struct {
+		http.ResponseWriter
+
+		status int
+		size   int
+		body   *bytes.Buffer
+	}
+
+	readCloser struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+// middlewares цепочка по умолчанию, повторяет интерсепторы grpc сервера: apm, метрики, логирование,
+// recovery, sentry и кастомные middleware из группы `http_middleware`.
+func middlewares(
+	logger *zap.Logger,
+	tracer *apm.Tracer,
+	customMiddlewares [][]mux.MiddlewareFunc,
+	config Config,
+) []mux.MiddlewareFunc {
+	mws := []mux.MiddlewareFunc{
+		tracingMiddleware(tracer),
+	}
+
+	if config.WithServerMetrics {
+		mws = append(mws, metricsMiddleware())
+	}
+
+	mws = append(mws,
+		loggingMiddleware(logger, config),
+		recoveryMiddleware(logger),
+		sentryhttp.New(sentryhttp.Options{Repanic: true}).Handle,
+	)
+
+	for _, customMws := range customMiddlewares {
+		mws = append(mws, customMws...)
+	}
+
+	return mws
+}
+
+func tracingMiddleware(tracer *apm.Tracer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return apmhttp.Wrap(
+			next,
+			apmhttp.WithTracer(tracer),
+			apmhttp.WithServerRequestName(func(r *http.Request) string {
+				return r.Method + " " + routeTemplate(r)
+			}),
+		)
+	}
+}
+
+func metricsMiddleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := wrapResponseWriter(w, false)
+
+			defer func(start time.Time) {
+				types.ServerHTTPMetrics.
+					WithLabelValues(r.Method, routeTemplate(r), strconv.Itoa(rw.statusCode())).
+					Observe(time.Since(start).Seconds())
+			}(time.Now())
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func loggingMiddleware(logger *zap.Logger, config Config) mux.MiddlewareFunc {
+	apiLogger := logger.Named("api")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(r)
+			routeFld := zap.String("http.route", route)
+			requestField := zap.Skip()
+
+			if config.WithDebugLog {
+				requestField = zap.String("http.payload", readRequestBody(r, config.RedactKeys))
+			}
+
+			apiLogger.Info(
+				fmt.Sprintf("http call %s %s", r.Method, route),
+				routeFld,
+				zap.String("http.method", r.Method),
+				zap.String("http.path", r.URL.Path),
+				zap.String("http.remote_addr", r.RemoteAddr),
+				zap.Any("http.headers", redact.Headers(r.Header, config.RedactHeaders)),
+				requestField,
+				fieldWithTraceID(r),
+			)
+
+			rw := wrapResponseWriter(w, config.WithDebugLog)
+			start := time.Now()
+
+			next.ServeHTTP(rw, r)
+
+			code := rw.statusCode()
+			responseField := zap.Skip()
+
+			if rw.body != nil {
+				responseField = zap.String("http.payload", redact.Body(rw.body.Bytes(), config.RedactKeys))
+			}
+
+			apiLogger.Log(
+				statusToLevel(code),
+				fmt.Sprintf("finished http call with code %d", code),
+				routeFld,
+				zap.Int("http.code", code),
+				zap.Int("http.size", rw.size),
+				zap.Duration("http.duration", time.Since(start)),
+				responseField,
+				fieldWithTraceID(r),
+			)
+		})
+	}
+}
+
+func recoveryMiddleware(logger *zap.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+
+				types.HTTPPanicInc()
+
+				logger.Error(
+					"recovered panic",
+					zap.String("panic value", fmt.Sprintf("%v", p)),
+					zap.ByteString("stacktrace", debug.Stack()),
+					fieldWithTraceID(r),
+				)
+
+				if rw, ok := w.(*responseWriter); ok && rw.status != 0 {
+					return
+				}
+
+				WriteProblem(w, r, ferrors.NewInternalError(fmt.Errorf("%v", p)))
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return unknownRoute
+	}
+
+	if tpl, err := route.GetPathTemplate(); err == nil {
+		return tpl
+	}
+
+	if prefix, err := route.GetPathRegexp(); err == nil {
+		return prefix
+	}
+
+	return unknownRoute
+}
+
+func statusToLevel(code int) zapcore.Level {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return zap.ErrorLevel
+	case code >= http.StatusBadRequest:
+		return zap.WarnLevel
+	default:
+		return zap.InfoLevel
+	}
+}
+
+// readRequestBody вычитывает тело для лога и подкладывает его обратно, хендлер получает его целиком
+func readRequestBody(r *http.Request, redactKeys []string) string {
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(r.Body, redact.MaxBody+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+
+	if err != nil {
+		return "read: " + err.Error()
+	}
+
+	return redact.Body(buf, redactKeys)
+}
+
+func fieldWithTraceID(r *http.Request) zap.Field {
+	trCtx := apm.TransactionFromContext(r.Context()).TraceContext()
+	if trCtx.Trace.Validate() == nil {
+		return zap.String("trace.id", trCtx.Trace.String())
+	}
+	return zap.Skip()
+}
+
+func wrapResponseWriter(w http.ResponseWriter, withBody bool) *responseWriter {
+	rw, ok := w.(*responseWriter)
+	if !ok {
+		rw = &responseWriter{ResponseWriter: w}
+	}
+
+	if withBody && rw.body == nil {
+		rw.body = &bytes.Buffer{}
+	}
+
+	return rw
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+
+	if w.body != nil && w.body.Len() <= redact.MaxBody {
+		w.body.Write(b[:n])
+	}
+
+	return n, err
+}
+
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Flush нужен стримингу grpc-gateway
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("hijack is not supported")
+	}
+
+	return h.Hijack()
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
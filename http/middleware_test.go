@@ -0,0 +1,49 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.elastic.co/apm/v2"
+	"go.elastic.co/apm/v2/transport"
+	"go.uber.org/zap"
+)
+
+func TestMiddlewares(t *testing.T) {
+	tracer, err := apm.NewTracerOptions(apm.TracerOptions{Transport: transport.Discard})
+	require.NoError(t, err)
+	defer tracer.Close()
+
+	r := mux.NewRouter()
+	api := r.NewRoute().Subrouter()
+	api.Use(middlewares(zap.NewNop(), tracer, nil, Config{
+		WithDebugLog: true,
+		RedactKeys:   []string{"password"},
+	})...)
+
+	var body string
+	api.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+
+		if mux.Vars(r)["id"] == "panic" {
+			panic("boom")
+		}
+	}).Methods(http.MethodPost)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader(`{"password":"x"}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, `{"password":"x"}`, body, "handler must get the body after logging")
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/users/panic", nil))
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	require.NotContains(t, rec.Body.String(), "boom", "panic value must stay in the log")
+}
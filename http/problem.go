@@ -0,0 +1,230 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+	"github.com/nenormalka/freya/types/errors/grpc"
+
+	"github.com/golang/protobuf/proto"
+	"go.uber.org/dig"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypeBlank   = "about:blank"
+)
+
+type (
+	// Problem тело ошибки по RFC 7807
+	Problem struct {
+		Type     string `json:"type"`
+		Title    string `json:"title"`
+		Status   int    `json:"status"`
+		Detail   string `json:"detail,omitempty"`
+		Instance string `json:"instance,omitempty"`
+		// Code имя grpc кода, тот же, что вернул бы grpc сервер
+		Code          string            `json:"code"`
+		InvalidParams []InvalidParam    `json:"invalid-params,omitempty"`
+		Details       map[string]string `json:"details,omitempty"`
+
+		// члены-расширения из типизированных деталей ошибки, те же, что уходят в grpc статус
+
+		// RetryAfter через сколько секунд можно повторить запрос, дублируется в заголовке Retry-After
+		RetryAfter           int64              `json:"retry-after,omitempty"`
+		PreconditionFailures []ProblemViolation `json:"precondition-failures,omitempty"`
+		QuotaViolations      []ProblemViolation `json:"quota-violations,omitempty"`
+		Resources            []ProblemResource  `json:"resources,omitempty"`
+		Help                 []ProblemHelpLink  `json:"help,omitempty"`
+	}
+
+	InvalidParam struct {
+		Name   string `json:"name"`
+		Reason string `json:"reason"`
+	}
+
+	ProblemViolation struct {
+		Type        string `json:"type,omitempty"`
+		Subject     string `json:"subject"`
+		Description string `json:"description,omitempty"`
+	}
+
+	ProblemResource struct {
+		Type        string `json:"type"`
+		Name        string `json:"name"`
+		Owner       string `json:"owner,omitempty"`
+		Description string `json:"description,omitempty"`
+	}
+
+	ProblemHelpLink struct {
+		Description string `json:"description,omitempty"`
+		URL         string `json:"url"`
+	}
+
+	// ErrorHandlerFunc хендлер, который возвращает ошибку, а не пишет её сам
+	ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+	ProblemsIn struct {
+		dig.In
+
+		Classifiers []ferrors.Classifier `group:"error_classifiers"`
+		Logger      *zap.Logger
+	}
+
+	// Problems рендерит ошибки в application/problem+json с теми же классификаторами и кодами,
+	// что и интерсептор grpc сервера.
+	Problems struct {
+		classifiers ferrors.Classifiers
+		logger      *zap.Logger
+	}
+)
+
+func NewProblems(in ProblemsIn) *Problems {
+	return &Problems{
+		classifiers: append(ferrors.Classifiers(in.Classifiers), ferrors.DefaultClassifiers...),
+		logger:      in.Logger,
+	}
+}
+
+// Handle превращает ErrorHandlerFunc в http.Handler, ошибка рендерится через Write
+func (p *Problems) Handle(f ErrorHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := f(w, r); err != nil {
+			p.Write(w, r, err)
+		}
+	})
+}
+
+// Write классифицирует ошибку и пишет её клиенту. Если классификатор спрятал исходный текст или
+// ошибка внутренняя (её текст клиенту не отдаётся), исходная ошибка остаётся только в логе.
+func (p *Problems) Write(w http.ResponseWriter, r *http.Request, err error) {
+	classified := p.classifiers.Classify(err)
+
+	switch {
+	case err == nil:
+	case hidesDetail(classified):
+		p.logger.Error("internal error", zap.String("http.path", r.URL.Path), zap.Error(err), fieldWithTraceID(r))
+	case classified.Error() != err.Error():
+		p.logger.Warn("classified error", zap.String("http.path", r.URL.Path), zap.Error(err), fieldWithTraceID(r))
+	}
+
+	WriteProblem(w, r, classified)
+}
+
+// WriteProblem пишет ошибку как problem+json. Ошибки, которые не являются *errors.Error,
+// отдаются как Unknown, так же как в grpc. Текст Internal и Unknown ошибок в ответ не попадает,
+// логировать его должен вызывающий.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	pr := NewProblem(err)
+	pr.Instance = r.URL.Path
+
+	w.Header().Set("Content-Type", problemContentType)
+	if pr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(pr.RetryAfter, 10))
+	}
+
+	w.WriteHeader(pr.Status)
+	_ = json.NewEncoder(w).Encode(pr)
+}
+
+func NewProblem(err error) Problem {
+	var e *ferrors.Error
+	if !errors.As(err, &e) || e == nil {
+		e = ferrors.NewUnknownError(err)
+	}
+
+	status := e.HTTPStatus()
+	code := e.CodeToGRPCCode()
+
+	pr := Problem{
+		Type:   problemTypeBlank,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: e.Error(),
+		Code:   code.String(),
+	}
+
+	if hidesDetail(e) {
+		pr.Detail = http.StatusText(status)
+	}
+
+	pr.addDetails(e.GRPCDetails())
+
+	if len(e.Details) == 0 {
+		return pr
+	}
+
+	if code != codes.InvalidArgument {
+		pr.Details = e.Details
+		return pr
+	}
+
+	pr.InvalidParams = make([]InvalidParam, 0, len(e.Details))
+	for name, reason := range e.Details {
+		pr.InvalidParams = append(pr.InvalidParams, InvalidParam{Name: name, Reason: reason})
+	}
+
+	sort.Slice(pr.InvalidParams, func(i, j int) bool {
+		return pr.InvalidParams[i].Name < pr.InvalidParams[j].Name
+	})
+
+	return pr
+}
+
+// hidesDetail текст внутренних ошибок (панику, ошибку драйвера и т.п.) клиенту не показываем
+func hidesDetail(err error) bool {
+	var e *ferrors.Error
+	if !errors.As(err, &e) || e == nil {
+		return true
+	}
+
+	return e.Code == ferrors.Internal || e.Code == ferrors.Unknown
+}
+
+func (pr *Problem) addDetails(details []proto.Message) {
+	for _, d := range details {
+		switch d := d.(type) {
+		case *grpc.RetryInfo:
+			// Retry-After в целых секундах, округляем вверх, чтобы клиент не пришёл раньше
+			if delay := d.GetRetryDelay().AsDuration(); delay > 0 {
+				pr.RetryAfter = int64((delay + time.Second - 1) / time.Second)
+			}
+		case *grpc.PreconditionFailure:
+			for _, v := range d.GetViolations() {
+				pr.PreconditionFailures = append(pr.PreconditionFailures, ProblemViolation{
+					Type:        v.GetType(),
+					Subject:     v.GetSubject(),
+					Description: v.GetDescription(),
+				})
+			}
+		case *grpc.QuotaFailure:
+			for _, v := range d.GetViolations() {
+				pr.QuotaViolations = append(pr.QuotaViolations, ProblemViolation{
+					Subject:     v.GetSubject(),
+					Description: v.GetDescription(),
+				})
+			}
+		case *grpc.ResourceInfo:
+			pr.Resources = append(pr.Resources, ProblemResource{
+				Type:        d.GetResourceType(),
+				Name:        d.GetResourceName(),
+				Owner:       d.GetOwner(),
+				Description: d.GetDescription(),
+			})
+		case *grpc.Help:
+			for _, l := range d.GetLinks() {
+				pr.Help = append(pr.Help, ProblemHelpLink{
+					Description: l.GetDescription(),
+					URL:         l.GetUrl(),
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ferrors "github.com/nenormalka/freya/types/errors"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestNewProblem(t *testing.T) {
+	pr := NewProblem(errors.New("boom"))
+	require.Equal(t, http.StatusInternalServerError, pr.Status)
+	require.Equal(t, "Unknown", pr.Code)
+	require.Equal(t, http.StatusText(http.StatusInternalServerError), pr.Detail)
+
+	pr = NewProblem(ferrors.NewInternalError(errors.New("pq: connection reset")))
+	require.Equal(t, "Internal", pr.Code)
+	require.Equal(t, http.StatusText(http.StatusInternalServerError), pr.Detail)
+
+	pr = NewProblem(ferrors.NewInvalidError(errors.New("invalid request")).
+		AddDetail("name", "required").
+		AddDetail("age", "must be positive"))
+	require.Equal(t, http.StatusBadRequest, pr.Status)
+	require.Equal(t, []InvalidParam{
+		{Name: "age", Reason: "must be positive"},
+		{Name: "name", Reason: "required"},
+	}, pr.InvalidParams)
+	require.Empty(t, pr.Details)
+
+	pr = NewProblem(ferrors.NewNotFoundError(errors.New("user not found")).
+		AddDetail("id", "1").
+		WithResourceInfo("user", "users/1", "", "deleted"))
+	require.Equal(t, map[string]string{"id": "1"}, pr.Details)
+	require.Equal(t, []ProblemResource{{Type: "user", Name: "users/1", Description: "deleted"}}, pr.Resources)
+
+	pr = NewProblem(ferrors.NewFailedPreconditionError(errors.New("order is paid")).
+		WithPreconditionViolation("STATE", "orders/1", "order is already paid").
+		WithHelpLink("orders", "https://example.com/orders"))
+	require.Equal(t, []ProblemViolation{
+		{Type: "STATE", Subject: "orders/1", Description: "order is already paid"},
+	}, pr.PreconditionFailures)
+	require.Equal(t, []ProblemHelpLink{{Description: "orders", URL: "https://example.com/orders"}}, pr.Help)
+}
+
+func TestWriteProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteProblem(rec, httptest.NewRequest(http.MethodGet, "/orders", nil), ferrors.NewResourceExhaustedError(errors.New("too many requests")).
+		WithRetryInfo(1500*time.Millisecond).
+		WithQuotaViolation("user:1", "100 requests per minute"))
+
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+	// заголовок в целых секундах, округление вверх
+	require.Equal(t, "2", rec.Header().Get("Retry-After"))
+	require.JSONEq(t, `{
+		"type": "about:blank",
+		"title": "Too Many Requests",
+		"status": 429,
+		"detail": "too many requests",
+		"instance": "/orders",
+		"code": "ResourceExhausted",
+		"retry-after": 2,
+		"quota-violations": [{"subject": "user:1", "description": "100 requests per minute"}]
+	}`, rec.Body.String())
+
+	var pr Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &pr))
+	require.Equal(t, int64(2), pr.RetryAfter)
+}
+
+func TestProblemsWriteInternal(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	problems := NewProblems(ProblemsIn{Logger: zap.New(core)})
+
+	rec := httptest.NewRecorder()
+	problems.Write(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil), errors.New("pq: connection reset"))
+
+	// клиенту текст статуса, исходная ошибка только в логе
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.NotContains(t, rec.Body.String(), "connection reset")
+	require.Equal(t, 1, logs.FilterMessage("internal error").Len())
+	require.Equal(t, "pq: connection reset", logs.All()[0].ContextMap()["error"])
+}
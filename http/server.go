@@ -11,7 +11,9 @@ import (
 	"github.com/nenormalka/freya/types"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.elastic.co/apm/v2"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +22,7 @@ type (
 		server *http.Server
 		logger *zap.Logger
 		cfg    Config
+		health *health
 	}
 
 	CustomServer interface {
@@ -28,28 +31,43 @@ type (
 	}
 )
 
-func NewHTTP(config Config, logger *zap.Logger, customServerList CustomServerList) (*Server, error) {
-	runtime.SetMutexProfileFraction(100)
-	runtime.SetBlockProfileRate(100)
-
+func NewHTTP(
+	config Config,
+	logger *zap.Logger,
+	tracer *apm.Tracer,
+	customServerList CustomServerList,
+	middlewareList MiddlewareList,
+	adminServerList AdminServerList,
+	probe *types.Probe,
+	healthCheckList HealthCheckList,
+	admin *Admin,
+) (*Server, error) {
 	r := mux.NewRouter()
 
-	r.HandleFunc("/debug/pprof/", pprof.Index)
-	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
-	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
-	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
-	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
-	r.HandleFunc("/debug/pprof/{cmd}", pprof.Index)
+	var (
+		healthHandler *health
+		err           error
+	)
 
-	r.Handle("/metrics", promhttp.Handler())
+	// с отдельным админ сервером служебные ручки живут только на нём
+	if admin == nil {
+		healthHandler, err = operationalRoutes(r, config, logger, probe, healthCheckList, adminServerList)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	r.Handle("/health", Handler(
-		WithReleaseID(config.ReleaseID),
-	))
+	if config.WithServerMetrics {
+		prometheus.MustRegister(types.ServerHTTPMetrics)
+	}
+
+	// служебные ручки выше живут без middleware, кастомные сервера получают подроутер с цепочкой
+	api := r.NewRoute().Subrouter()
+	api.Use(middlewares(logger, tracer, middlewareList.Middlewares, config)...)
 
 	for _, customServer := range customServerList.CustomServers {
 		logger.Info(fmt.Sprintf("register http server: `%s`", customServer.GetServerName()))
-		if err := customServer.StartServer(r); err != nil {
+		if err := customServer.StartServer(api); err != nil {
 			return nil, fmt.Errorf("set http routes error %w", err)
 		}
 	}
@@ -62,12 +80,15 @@ func NewHTTP(config Config, logger *zap.Logger, customServerList CustomServerLis
 		},
 		logger: logger,
 		cfg:    config,
+		health: healthHandler,
 	}, nil
 }
 
 func (s *Server) Start(ctx context.Context) error {
 	s.logger.Info("HTTP server started, listening on address: ", zap.String("http start", s.cfg.ListenAddr))
 
+	s.health.start(ctx)
+
 	return types.StartServerWithWaiting(ctx, s.logger, func(errCh chan error) {
 		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.logger.Error("http server err", zap.Error(err))
@@ -79,5 +100,60 @@ func (s *Server) Start(ctx context.Context) error {
 }
 
 func (s *Server) Stop(ctx context.Context) error {
+	s.health.stop()
+
 	return s.server.Shutdown(ctx)
 }
+
+// operationalRoutes вешает профайлер, метрики, health и кастомные админские ручки. Возвращает health хендлер,
+// фоновое обновление которого запускает владелец роутера, /health/ready читает его результаты.
+func operationalRoutes(
+	r *mux.Router,
+	config Config,
+	logger *zap.Logger,
+	probe *types.Probe,
+	healthCheckList HealthCheckList,
+	adminServerList AdminServerList,
+) (*health, error) {
+	runtime.SetMutexProfileFraction(100)
+	runtime.SetBlockProfileRate(100)
+
+	r.HandleFunc("/debug/pprof/", pprof.Index)
+	r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	r.HandleFunc("/debug/pprof/{cmd}", pprof.Index)
+
+	r.Handle("/metrics", promhttp.Handler())
+
+	healthChecks := make([]Option, 0, len(healthCheckList.HealthChecks)+1)
+	healthChecks = append(healthChecks, WithBackgroundRefresh(config.HealthRefreshInterval, config.HealthStaleAfter))
+	for _, hc := range healthCheckList.HealthChecks {
+		if err := hc.Validate(); err != nil {
+			return nil, fmt.Errorf("register health check error %w", err)
+		}
+
+		healthChecks = append(healthChecks, WithHealthCheck(hc))
+	}
+
+	healthHandler := newHealth(append([]Option{WithReleaseID(config.ReleaseID)}, healthChecks...)...)
+	readyHandler := newHealth(WithReleaseID(config.ReleaseID), withChecksOf(healthHandler), WithReadiness(probe))
+
+	r.Handle("/health", healthHandler)
+
+	r.Handle("/health/live", Handler(
+		WithReleaseID(config.ReleaseID),
+	))
+
+	r.Handle("/health/ready", readyHandler)
+
+	for _, adminServer := range adminServerList.AdminServers {
+		logger.Info(fmt.Sprintf("register admin http server: `%s`", adminServer.GetServerName()))
+		if err := adminServer.StartServer(r); err != nil {
+			return nil, fmt.Errorf("set admin http routes error %w", err)
+		}
+	}
+
+	return healthHandler, nil
+}
@@ -0,0 +1,114 @@
+// Package redact готовит тела запросов и ответов к логированию: вычищает сенситивные данные.
+// Общий для серверов и клиентов, чтобы клиенты не зависели от серверных пакетов.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nenormalka/bishamon"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// MaxBody тела больше этого размера не логируются даже с DebugLog
+	MaxBody  = 64 << 10
+	Redacted = "[REDACTED]"
+)
+
+// Proto сериализует proto сообщение в json для логов, предварительно вычищая сенситивные поля
+func Proto(msg any, redactor *bishamon.Redactor) string {
+	p, ok := msg.(proto.Message)
+	if !ok {
+		return "msg is not proto.Message"
+	}
+
+	redactedMessage := proto.Clone(p)
+	if redactor != nil {
+		if err := redactor.Redact(redactedMessage); err != nil {
+			return "redact: " + err.Error()
+		}
+	}
+
+	bytes, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(redactedMessage)
+	if err != nil {
+		return "marshal: " + err.Error()
+	}
+
+	return string(bytes)
+}
+
+// Body вычищает значения ключей из json тела для логов. Не json не логируется, так как вычистить его нельзя
+func Body(body []byte, keys []string) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if len(body) > MaxBody {
+		return fmt.Sprintf("body too large: more than %d bytes", MaxBody)
+	}
+
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("non-json body: %d bytes", len(body))
+	}
+
+	b, err := json.Marshal(redactValue(v, keys))
+	if err != nil {
+		return "marshal: " + err.Error()
+	}
+
+	return string(b)
+}
+
+// Headers первые значения заголовков, значения из keys заменяются на Redacted
+func Headers(header http.Header, keys []string) map[string]string {
+	res := make(map[string]string, len(header))
+	for key, values := range header {
+		value := ""
+		if len(values) != 0 {
+			value = values[0]
+		}
+
+		if contains(keys, key) {
+			value = Redacted
+		}
+
+		res[key] = value
+	}
+
+	return res
+}
+
+func redactValue(v any, keys []string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if contains(keys, key) {
+				v[key] = Redacted
+				continue
+			}
+
+			v[key] = redactValue(value, keys)
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i], keys)
+		}
+	}
+
+	return v
+}
+
+func contains(list []string, key string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, key) {
+			return true
+		}
+	}
+
+	return false
+}
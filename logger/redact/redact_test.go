@@ -0,0 +1,35 @@
+package redact
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProto(t *testing.T) {
+	require.Equal(t, `"secret"`, Proto(wrapperspb.String("secret"), nil))
+	require.Equal(t, "msg is not proto.Message", Proto("secret", nil))
+}
+
+func TestBody(t *testing.T) {
+	keys := []string{"password", "token"}
+
+	require.JSONEq(t,
+		`{"login":"bob","password":"[REDACTED]","items":[{"Token":"[REDACTED]","id":1}]}`,
+		Body([]byte(`{"login":"bob","password":"secret","items":[{"Token":"abc","id":1}]}`), keys),
+	)
+	require.Equal(t, "non-json body: 9 bytes", Body([]byte("password="), keys))
+	require.Empty(t, Body(nil, keys))
+}
+
+func TestHeaders(t *testing.T) {
+	require.Equal(t, map[string]string{
+		"Authorization": Redacted,
+		"Accept":        "application/json",
+	}, Headers(http.Header{
+		"Authorization": {"Bearer secret"},
+		"Accept":        {"application/json"},
+	}, []string{"authorization"}))
+}
@@ -0,0 +1,49 @@
+package errors
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// Classifier распознаёт известную инфраструктурную ошибку и возвращает *Error с подходящим кодом.
+	// Если ошибка не распознана, возвращает nil.
+	Classifier func(err error) *Error
+
+	Classifiers []Classifier
+)
+
+// DefaultClassifiers применяются после зарегистрированных через группу `error_classifiers`
+var DefaultClassifiers = Classifiers{ContextClassifier}
+
+// Classify прогоняет ошибку по классификаторам до первого совпадения. *Error и нераспознанные ошибки
+// возвращаются как есть.
+func (cs Classifiers) Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	for _, c := range cs {
+		if e = c(err); e != nil {
+			return e
+		}
+	}
+
+	return err
+}
+
+func ContextClassifier(err error) *Error {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewDeadlineExceededError(err)
+	case errors.Is(err, context.Canceled):
+		return NewCanceledError(err)
+	default:
+		return nil
+	}
+}
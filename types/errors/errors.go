@@ -2,13 +2,16 @@ package errors
 
 import (
 	"errors"
+	"net/http"
 	"sort"
+	"time"
 
 	"github.com/nenormalka/freya/types/errors/grpc"
 
 	"github.com/golang/protobuf/proto"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 const (
@@ -19,6 +22,18 @@ const (
 	PermissionDenied
 	Internal
 	Unavailable
+	Unauthenticated
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	DeadlineExceeded
+	Canceled
+	Unimplemented
+)
+
+const (
+	// statusClientClosedRequest нестандартный статус nginx, его же отдаёт grpc-gateway для Canceled
+	statusClientClosedRequest = 499
 )
 
 type (
@@ -26,9 +41,36 @@ type (
 		Code    Code
 		err     error
 		Details map[string]string
+		// details типизированные детали (RetryInfo, QuotaFailure и т.д.), добавляются методами With*
+		details []proto.Message
 	}
 
 	Code int32
+
+	codeMapping struct {
+		grpc codes.Code
+		http int
+	}
+)
+
+var (
+	// codeTable единая таблица соответствия кодов freya кодам grpc и http статусам
+	codeTable = map[Code]codeMapping{
+		Unknown:            {grpc: codes.Unknown, http: http.StatusInternalServerError},
+		InvalidArgument:    {grpc: codes.InvalidArgument, http: http.StatusBadRequest},
+		NotFound:           {grpc: codes.NotFound, http: http.StatusNotFound},
+		AlreadyExists:      {grpc: codes.AlreadyExists, http: http.StatusConflict},
+		PermissionDenied:   {grpc: codes.PermissionDenied, http: http.StatusForbidden},
+		Internal:           {grpc: codes.Internal, http: http.StatusInternalServerError},
+		Unavailable:        {grpc: codes.Unavailable, http: http.StatusServiceUnavailable},
+		Unauthenticated:    {grpc: codes.Unauthenticated, http: http.StatusUnauthorized},
+		ResourceExhausted:  {grpc: codes.ResourceExhausted, http: http.StatusTooManyRequests},
+		FailedPrecondition: {grpc: codes.FailedPrecondition, http: http.StatusBadRequest},
+		Aborted:            {grpc: codes.Aborted, http: http.StatusConflict},
+		DeadlineExceeded:   {grpc: codes.DeadlineExceeded, http: http.StatusGatewayTimeout},
+		Canceled:           {grpc: codes.Canceled, http: statusClientClosedRequest},
+		Unimplemented:      {grpc: codes.Unimplemented, http: http.StatusNotImplemented},
+	}
 )
 
 func NewInvalidError(err error) *Error {
@@ -55,6 +97,34 @@ func NewUnavailableError(err error) *Error {
 	return NewError(Unavailable, err)
 }
 
+func NewUnauthenticatedError(err error) *Error {
+	return NewError(Unauthenticated, err)
+}
+
+func NewResourceExhaustedError(err error) *Error {
+	return NewError(ResourceExhausted, err)
+}
+
+func NewFailedPreconditionError(err error) *Error {
+	return NewError(FailedPrecondition, err)
+}
+
+func NewAbortedError(err error) *Error {
+	return NewError(Aborted, err)
+}
+
+func NewDeadlineExceededError(err error) *Error {
+	return NewError(DeadlineExceeded, err)
+}
+
+func NewCanceledError(err error) *Error {
+	return NewError(Canceled, err)
+}
+
+func NewUnimplementedError(err error) *Error {
+	return NewError(Unimplemented, err)
+}
+
 func NewUnknownError(err error) *Error {
 	return NewError(Unknown, err)
 }
@@ -75,6 +145,10 @@ func (e Error) Error() string {
 	return e.err.Error()
 }
 
+func (e Error) Unwrap() error {
+	return e.err
+}
+
 func (e *Error) SetDetails(details map[string]string) *Error {
 	e.Details = details
 	return e
@@ -115,22 +189,187 @@ func (e *Error) CodeToGRPCCode() codes.Code {
 		return codes.Unknown
 	}
 
-	switch e.Code {
-	case InvalidArgument:
-		return codes.InvalidArgument
-	case NotFound:
-		return codes.NotFound
-	case AlreadyExists:
-		return codes.AlreadyExists
-	case PermissionDenied:
-		return codes.PermissionDenied
-	case Internal:
-		return codes.Internal
-	case Unavailable:
-		return codes.Unavailable
-	default:
-		return codes.Unknown
+	if m, ok := codeTable[e.Code]; ok {
+		return m.grpc
+	}
+
+	return codes.Unknown
+}
+
+// HTTPStatus http статус, соответствующий коду ошибки
+func (e *Error) HTTPStatus() int {
+	if e == nil {
+		return http.StatusInternalServerError
+	}
+
+	if m, ok := codeTable[e.Code]; ok {
+		return m.http
+	}
+
+	return http.StatusInternalServerError
+}
+
+// GRPCDetails возвращает все детали ошибки: собранные из Details и добавленные методами With*
+func (e *Error) GRPCDetails() []proto.Message {
+	if e == nil {
+		return nil
+	}
+
+	details := make([]proto.Message, 0, len(e.details)+1)
+	if d := e.DetailsToGRPCDetails(); d != nil {
+		details = append(details, d)
+	}
+
+	return append(details, e.details...)
+}
+
+// Методы With* держат не больше одной детали каждого типа: повторный вызов дописывает элемент в списочные
+// детали (QuotaFailure, PreconditionFailure, Help) и перезаписывает поля остальных. На nil ошибке ничего не делают.
+
+// WithRetryInfo подсказывает клиенту, через сколько можно повторить запрос
+func (e *Error) WithRetryInfo(delay time.Duration) *Error {
+	if e == nil {
+		return nil
+	}
+
+	detail(e, func() *grpc.RetryInfo { return &grpc.RetryInfo{} }).RetryDelay = durationpb.New(delay)
+
+	return e
+}
+
+// WithQuotaViolation добавляет нарушение квоты, можно вызывать несколько раз
+func (e *Error) WithQuotaViolation(subject, description string) *Error {
+	if e == nil {
+		return nil
+	}
+
+	d := detail(e, func() *grpc.QuotaFailure { return &grpc.QuotaFailure{} })
+	d.Violations = append(d.Violations, &grpc.QuotaFailure_Violation{
+		Subject:     subject,
+		Description: description,
+	})
+
+	return e
+}
+
+// WithPreconditionViolation добавляет невыполненное предусловие, можно вызывать несколько раз
+func (e *Error) WithPreconditionViolation(violationType, subject, description string) *Error {
+	if e == nil {
+		return nil
+	}
+
+	d := detail(e, func() *grpc.PreconditionFailure { return &grpc.PreconditionFailure{} })
+	d.Violations = append(d.Violations, &grpc.PreconditionFailure_Violation{
+		Type:        violationType,
+		Subject:     subject,
+		Description: description,
+	})
+
+	return e
+}
+
+// WithResourceInfo описывает ресурс, к которому относится ошибка
+func (e *Error) WithResourceInfo(resourceType, resourceName, owner, description string) *Error {
+	if e == nil {
+		return nil
+	}
+
+	d := detail(e, func() *grpc.ResourceInfo { return &grpc.ResourceInfo{} })
+	d.ResourceType = resourceType
+	d.ResourceName = resourceName
+	d.Owner = owner
+	d.Description = description
+
+	return e
+}
+
+func (e *Error) WithRequestInfo(requestID, servingData string) *Error {
+	if e == nil {
+		return nil
+	}
+
+	d := detail(e, func() *grpc.RequestInfo { return &grpc.RequestInfo{} })
+	d.RequestId = requestID
+	d.ServingData = servingData
+
+	return e
+}
+
+// WithHelpLink добавляет ссылку на документацию, можно вызывать несколько раз
+func (e *Error) WithHelpLink(description, url string) *Error {
+	if e == nil {
+		return nil
 	}
+
+	d := detail(e, func() *grpc.Help { return &grpc.Help{} })
+	d.Links = append(d.Links, &grpc.Help_Link{
+		Description: description,
+		Url:         url,
+	})
+
+	return e
+}
+
+// WithLocalizedMessage сообщение для пользователя, локаль клиент выбирает сам при запросе
+func (e *Error) WithLocalizedMessage(locale, message string) *Error {
+	if e == nil {
+		return nil
+	}
+
+	d := detail(e, func() *grpc.LocalizedMessage { return &grpc.LocalizedMessage{} })
+	d.Locale = locale
+	d.Message = message
+
+	return e
+}
+
+func (e *Error) WithDebugInfo(stackEntries []string, debugDetail string) *Error {
+	if e == nil {
+		return nil
+	}
+
+	d := detail(e, func() *grpc.DebugInfo { return &grpc.DebugInfo{} })
+	d.StackEntries = stackEntries
+	d.Detail = debugDetail
+
+	return e
+}
+
+// detail возвращает уже добавленную деталь типа T или добавляет новую
+func detail[T proto.Message](e *Error, newDetail func() T) T {
+	for _, d := range e.details {
+		if t, ok := d.(T); ok {
+			return t
+		}
+	}
+
+	t := newDetail()
+	e.details = append(e.details, t)
+
+	return t
+}
+
+// GRPCStatus позволяет status.FromError и status.Code понимать *Error без ErrorToGRPCError.
+// Статус собирается здесь же: через status.FromError(e) он вызвал бы сам себя.
+func (e *Error) GRPCStatus() *status.Status {
+	return e.grpcStatus(e.Error())
+}
+
+// grpcStatus статус с деталями, если детали не сериализуются (например, невалидный UTF-8) - без них
+func (e *Error) grpcStatus(msg string) *status.Status {
+	st := status.New(e.CodeToGRPCCode(), msg)
+
+	details := e.GRPCDetails()
+	if len(details) == 0 {
+		return st
+	}
+
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+
+	return withDetails
 }
 
 func (e *Error) detailsDefault() proto.Message {
@@ -171,16 +410,71 @@ func ErrorToGRPCError(err error) error {
 		return err
 	}
 
-	st := status.New(e.CodeToGRPCCode(), err.Error())
+	return e.grpcStatus(err.Error()).Err()
+}
+
+// DetailsFromStatus собирает Details обратно из деталей grpc статуса: BadRequest и ErrorInfo
+func DetailsFromStatus(st *status.Status) map[string]string {
+	details := make(map[string]string)
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *grpc.BadRequest:
+			for _, v := range d.GetFieldViolations() {
+				details[v.GetField()] = v.GetDescription()
+			}
+		case *grpc.ErrorInfo:
+			for k, v := range d.GetMetadata() {
+				details[k] = v
+			}
+		}
+	}
+
+	return details
+}
+
+// FromGRPCError собирает *Error обратно из ошибки со grpc статусом. Details восстанавливаются
+// из BadRequest и ErrorInfo, остальные детали доступны через GRPCDetails. Ошибки без статуса
+// возвращаются как есть.
+func FromGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	if st.Code() == codes.OK {
+		return nil
+	}
+
+	e = NewError(codeFromGRPCCode(st.Code()), errors.New(st.Message()))
+	e.Details = DetailsFromStatus(st)
+
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *grpc.BadRequest, *grpc.ErrorInfo:
+		case proto.Message:
+			e.details = append(e.details, d)
+		}
+	}
+
+	return e
+}
 
-	details := e.DetailsToGRPCDetails()
-	if details != nil {
-		var errS error
-		st, errS = st.WithDetails(details)
-		if errS != nil {
-			return err
+func codeFromGRPCCode(code codes.Code) Code {
+	for c, m := range codeTable {
+		if m.grpc == code {
+			return c
 		}
 	}
 
-	return st.Err()
+	return Unknown
 }
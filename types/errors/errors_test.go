@@ -0,0 +1,204 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nenormalka/freya/types/errors/grpc"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorToGRPCError(t *testing.T) {
+	err := NewResourceExhaustedError(errors.New("too many requests")).
+		AddDetail("user_id", "42").
+		WithRetryInfo(time.Second).
+		WithQuotaViolation("user:42", "requests per minute").
+		WithQuotaViolation("user:42", "requests per day")
+
+	st, ok := status.FromError(ErrorToGRPCError(err))
+	require.True(t, ok)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Equal(t, "too many requests", st.Message())
+
+	details := st.Details()
+	require.Len(t, details, 3)
+
+	require.Equal(t, map[string]string{"user_id": "42"}, details[0].(*grpc.ErrorInfo).GetMetadata())
+	require.Equal(t, time.Second, details[1].(*grpc.RetryInfo).GetRetryDelay().AsDuration())
+	require.Len(t, details[2].(*grpc.QuotaFailure).GetViolations(), 2)
+}
+
+func TestWithDetails(t *testing.T) {
+	tests := []struct {
+		name  string
+		with  func(e *Error) *Error
+		check func(t *testing.T, details []proto.Message)
+	}{
+		{
+			name: "retry info overwritten",
+			with: func(e *Error) *Error {
+				return e.WithRetryInfo(time.Second).WithRetryInfo(time.Minute)
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				require.Equal(t, time.Minute, details[0].(*grpc.RetryInfo).GetRetryDelay().AsDuration())
+			},
+		},
+		{
+			name: "quota violations appended",
+			with: func(e *Error) *Error {
+				return e.WithQuotaViolation("user:1", "per minute").WithQuotaViolation("user:1", "per day")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				require.Len(t, details[0].(*grpc.QuotaFailure).GetViolations(), 2)
+			},
+		},
+		{
+			name: "precondition violations appended",
+			with: func(e *Error) *Error {
+				return e.WithPreconditionViolation("TOS", "user:1", "not accepted").
+					WithPreconditionViolation("STATE", "order:1", "paid")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				require.Len(t, details[0].(*grpc.PreconditionFailure).GetViolations(), 2)
+			},
+		},
+		{
+			name: "help links appended",
+			with: func(e *Error) *Error {
+				return e.WithHelpLink("a", "https://a.example.com").WithHelpLink("b", "https://b.example.com")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				require.Len(t, details[0].(*grpc.Help).GetLinks(), 2)
+			},
+		},
+		{
+			name: "resource info overwritten",
+			with: func(e *Error) *Error {
+				return e.WithResourceInfo("user", "users/1", "", "").WithResourceInfo("user", "users/2", "team", "deleted")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				d := details[0].(*grpc.ResourceInfo)
+				require.Equal(t, "users/2", d.GetResourceName())
+				require.Equal(t, "team", d.GetOwner())
+			},
+		},
+		{
+			name: "request info overwritten",
+			with: func(e *Error) *Error {
+				return e.WithRequestInfo("1", "").WithRequestInfo("2", "node-1")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				require.Equal(t, "2", details[0].(*grpc.RequestInfo).GetRequestId())
+			},
+		},
+		{
+			name: "localized message overwritten",
+			with: func(e *Error) *Error {
+				return e.WithLocalizedMessage("en-US", "not found").WithLocalizedMessage("ru-RU", "не найдено")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				d := details[0].(*grpc.LocalizedMessage)
+				require.Equal(t, "ru-RU", d.GetLocale())
+				require.Equal(t, "не найдено", d.GetMessage())
+			},
+		},
+		{
+			name: "debug info overwritten",
+			with: func(e *Error) *Error {
+				return e.WithDebugInfo([]string{"a"}, "first").WithDebugInfo([]string{"b"}, "second")
+			},
+			check: func(t *testing.T, details []proto.Message) {
+				d := details[0].(*grpc.DebugInfo)
+				require.Equal(t, []string{"b"}, d.GetStackEntries())
+				require.Equal(t, "second", d.GetDetail())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := tt.with(NewNotFoundError(errors.New("not found"))).GRPCDetails()
+			require.Len(t, details, 1)
+			tt.check(t, details)
+
+			// детали переживают сериализацию в grpc статус
+			st, ok := status.FromError(ErrorToGRPCError(tt.with(NewNotFoundError(errors.New("not found")))))
+			require.True(t, ok)
+			require.Len(t, st.Details(), 1)
+
+			require.Nil(t, tt.with(nil))
+		})
+	}
+}
+
+func TestGRPCStatusInvalidDetails(t *testing.T) {
+	// невалидный UTF-8 в деталях не сериализуется в proto, статус отдаётся без деталей
+	err := NewInvalidError(errors.New("invalid request")).AddDetail("f", "\xff")
+
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Equal(t, "invalid request", st.Message())
+	require.Empty(t, st.Details())
+
+	st, ok = status.FromError(ErrorToGRPCError(fmt.Errorf("wrapped: %w", err)))
+	require.True(t, ok)
+	require.Equal(t, codes.InvalidArgument, st.Code())
+	require.Equal(t, "wrapped: invalid request", st.Message())
+}
+
+func TestFromGRPCError(t *testing.T) {
+	src := NewInvalidError(errors.New("invalid request")).
+		AddDetail("email", "must not be empty").
+		WithHelpLink("docs", "https://example.com")
+
+	err := FromGRPCError(ErrorToGRPCError(src))
+
+	var e *Error
+	require.True(t, errors.As(err, &e))
+	require.Equal(t, InvalidArgument, e.Code)
+	require.Equal(t, "invalid request", e.Error())
+	require.Equal(t, map[string]string{"email": "must not be empty"}, e.Details)
+	require.Len(t, e.GRPCDetails(), 2)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	plain := errors.New("plain")
+	require.Equal(t, plain, FromGRPCError(plain))
+	require.Equal(t, Unknown, FromGRPCError(status.Error(codes.DataLoss, "lost")).(*Error).Code)
+}
+
+func TestClassifiers(t *testing.T) {
+	errCustom := errors.New("custom")
+
+	cs := append(Classifiers{func(err error) *Error {
+		if errors.Is(err, errCustom) {
+			return NewPermissionDeniedError(err)
+		}
+
+		return nil
+	}}, DefaultClassifiers...)
+
+	for name, tt := range map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"custom":   {err: fmt.Errorf("wrap: %w", errCustom), code: codes.PermissionDenied},
+		"deadline": {err: context.DeadlineExceeded, code: codes.DeadlineExceeded},
+		"canceled": {err: context.Canceled, code: codes.Canceled},
+		"freya":    {err: NewNotFoundError(context.Canceled), code: codes.NotFound},
+		"unknown":  {err: errors.New("boom"), code: codes.Unknown},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := cs.Classify(tt.err)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.code, status.Code(ErrorToGRPCError(err)))
+		})
+	}
+}
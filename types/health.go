@@ -0,0 +1,49 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrHealthCheckNoFunc = errors.New("health check func is not set")
+)
+
+type (
+	// HealthCheck проверка зависимости, которую можно повесить на health ручки http и grpc,
+	// экспортировав её в группу `group:"health_checkers"`.
+	HealthCheck struct {
+		// Name имя проверки, под ним она видна в json ответе и как имя сервиса в grpc Health
+		Name string
+		// Check возвращает nil, если зависимость в порядке
+		Check func(ctx context.Context) error
+		// Critical роняет общий статус при ошибке. Иначе проверка только отображается (observer)
+		Critical bool
+		// Timeout собственный таймаут проверки, если не задан, то действует общий таймаут ручки
+		Timeout time.Duration
+		// Interval собственный интервал фонового обновления (HEALTH_REFRESH_INTERVAL), если не задан - общий
+		Interval time.Duration
+	}
+)
+
+// Validate проверяется при регистрации, чтобы проверка без Check не падала паникой в Run
+func (hc HealthCheck) Validate() error {
+	if hc.Check == nil {
+		return fmt.Errorf("%w: `%s`", ErrHealthCheckNoFunc, hc.Name)
+	}
+
+	return nil
+}
+
+// Run выполняет проверку с учётом её таймаута.
+func (hc HealthCheck) Run(ctx context.Context) error {
+	if hc.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hc.Timeout)
+		defer cancel()
+	}
+
+	return hc.Check(ctx)
+}
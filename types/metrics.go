@@ -33,6 +33,22 @@ var (
 		Help:      "Number of db errors.",
 	}, []string{"query_name"})
 
+	// DBTxRetryMetrics повторы транзакций после ошибок сериализации и дедлоков, query_name - txName
+	DBTxRetryMetrics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "connections",
+		Subsystem: "sql",
+		Name:      "tx_retry_total",
+		Help:      "Number of transaction retries.",
+	}, []string{"query_name"})
+
+	// DBRowsMetrics строки, прочитанные потоковыми запросами (SQLXRows, GoQuRows, PGXRows и keyset обходы)
+	DBRowsMetrics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "connections",
+		Subsystem: "sql",
+		Name:      "rows_total",
+		Help:      "Number of rows read by streaming queries.",
+	}, []string{"query_name"})
+
 	CouchbaseMetrics = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Namespace: "connections",
@@ -117,6 +133,48 @@ var (
 	ServerGRPCMetrics = grpcprom.NewServerMetrics(
 		grpcprom.WithServerHandlingTimeHistogram(),
 	)
+
+	ClientGRPCMetrics = grpcprom.NewClientMetrics(
+		grpcprom.WithClientHandlingTimeHistogram(),
+	)
+
+	// ServerHTTPMetrics регистрируется http сервером при ENABLE_SERVER_METRICS, route - шаблон пути из mux
+	ServerHTTPMetrics = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "http",
+			Subsystem: "server",
+			Name:      "handling_seconds",
+			Help:      "request handling duration seconds",
+			Buckets:   []float64{.005, .01, .025, .05, .075, .1, .15, .2, .25, .5, 1, 2.5},
+		}, []string{"method", "route", "code"},
+	)
+
+	ResilienceBreakerStateMetrics = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "connections",
+		Subsystem: "resilience",
+		Name:      "breaker_state",
+		Help:      "Circuit breaker state: 0 - closed, 1 - half-open, 2 - open.",
+	}, []string{"name"})
+
+	ResilienceInFlightMetrics = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "connections",
+		Subsystem: "resilience",
+		Name:      "in_flight",
+		Help:      "Number of concurrent calls.",
+	}, []string{"name"})
+
+	ResilienceRejectedMetrics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "connections",
+		Subsystem: "resilience",
+		Name:      "rejected_total",
+		Help:      "Number of calls rejected by circuit breaker or bulkhead.",
+	}, []string{"name", "reason"})
+
+	HTTPPanicMetrics = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "http",
+		Name:      "panic_total",
+		Help:      "Number of http panic.",
+	})
 )
 
 func SetApplicationMetrics() {
@@ -133,6 +191,10 @@ func GRPCPanicInc() {
 	GRPCPanicMetrics.Inc()
 }
 
+func HTTPPanicInc() {
+	HTTPPanicMetrics.Inc()
+}
+
 func KafkaSyncProducerMetricsF(topic string, err error) {
 	KafkaSyncProducerMetrics.
 		WithLabelValues(topic, errToBoolString(err)).
@@ -211,12 +273,20 @@ func WithSQLMetrics(
 ) error {
 	var err error
 	defer func(start time.Time) {
-		DBMetrics.
-			WithLabelValues(queryName, serviceName, errToBoolString(err)).
-			Observe(time.Since(start).Seconds())
+		ObserveSQLMetrics(queryName, serviceName, time.Since(start), err)
 	}(time.Now())
 
 	err = callFunc()
+	return err
+}
+
+// ObserveSQLMetrics пишет уже измеренную длительность запроса, например у потоковых запросов,
+// где время обработки строк вызывающим в метрику не входит
+func ObserveSQLMetrics(queryName, serviceName string, duration time.Duration, err error) {
+	DBMetrics.
+		WithLabelValues(queryName, serviceName, errToBoolString(err)).
+		Observe(duration.Seconds())
+
 	if isError(err) {
 		DBErrorMetrics.
 			With(prometheus.Labels{
@@ -224,8 +294,6 @@ func WithSQLMetrics(
 			}).
 			Inc()
 	}
-
-	return err
 }
 
 func errToBoolString(err error) string {
@@ -0,0 +1,80 @@
+package types
+
+import (
+	"sync"
+)
+
+const (
+	ProbeStarting ProbeState = iota
+	ProbeReady
+	ProbeStopping
+)
+
+type (
+	ProbeState int32
+
+	// Probe хранит состояние готовности приложения. App переключает его по ходу жизненного цикла,
+	// а health ручки http и grpc отдают наружу.
+	Probe struct {
+		mu        sync.RWMutex
+		state     ProbeState
+		listeners []func(state ProbeState)
+	}
+)
+
+func NewProbe() *Probe {
+	return &Probe{
+		state: ProbeStarting,
+	}
+}
+
+func (p *Probe) SetState(state ProbeState) {
+	p.mu.Lock()
+	if p.state == state {
+		p.mu.Unlock()
+		return
+	}
+
+	p.state = state
+	listeners := make([]func(state ProbeState), len(p.listeners))
+	copy(listeners, p.listeners)
+	p.mu.Unlock()
+
+	for _, f := range listeners {
+		f(state)
+	}
+}
+
+func (p *Probe) State() ProbeState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.state
+}
+
+func (p *Probe) IsReady() bool {
+	return p.State() == ProbeReady
+}
+
+// OnChange подписывает f на смену состояния. f сразу вызывается с текущим состоянием.
+func (p *Probe) OnChange(f func(state ProbeState)) {
+	p.mu.Lock()
+	p.listeners = append(p.listeners, f)
+	state := p.state
+	p.mu.Unlock()
+
+	f(state)
+}
+
+func (s ProbeState) String() string {
+	switch s {
+	case ProbeStarting:
+		return "starting"
+	case ProbeReady:
+		return "ready"
+	case ProbeStopping:
+		return "stopping"
+	default:
+		return "unknown"
+	}
+}
@@ -2,7 +2,10 @@ package types
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"go.uber.org/zap"
@@ -14,7 +17,39 @@ type (
 		Stop(ctx context.Context) error
 	}
 
+	// NamedRunnable задаёт имя, по которому на Runnable могут ссылаться другие Runnable из того же пула.
+	// Если Runnable не реализует интерфейс, именем считается его тип (`%T`).
+	NamedRunnable interface {
+		Runnable
+		Name() string
+	}
+
+	// DependentRunnable перечисляет имена Runnable, которые должны быть запущены раньше него
+	// и остановлены позже.
+	DependentRunnable interface {
+		Runnable
+		DependsOn() []string
+	}
+
 	runnableType string
+
+	runnableNode struct {
+		name string
+		r    Runnable
+		deps []string
+		idx  int
+	}
+
+	// runnablePool хранит Runnable, разложенные по уровням топологической сортировки.
+	// Runnable одного уровня не зависят друг от друга и запускаются параллельно.
+	runnablePool struct {
+		levels [][]*runnableNode
+		logger *zap.Logger
+		name   runnableType
+
+		mu      sync.Mutex
+		started map[*runnableNode]struct{}
+	}
 )
 
 const (
@@ -22,41 +57,122 @@ const (
 	runnableService runnableType = "service"
 )
 
-func start(ctx context.Context, pool []Runnable, logger *zap.Logger, name runnableType) error {
-	logger.Info(fmt.Sprintf("Graceful: Run %s...", name))
+var (
+	ErrRunnableDuplicateName = errors.New("duplicate runnable name")
+	ErrRunnableUnknownDep    = errors.New("unknown runnable dependency")
+	ErrRunnableCycle         = errors.New("runnable dependency cycle")
+)
+
+func newRunnablePool(pool []Runnable, logger *zap.Logger, name runnableType) (*runnablePool, error) {
+	levels, err := sortRunnables(pool)
+	if err != nil {
+		return nil, fmt.Errorf("build %s pool: %w", name, err)
+	}
+
+	return &runnablePool{
+		levels:  levels,
+		logger:  logger,
+		name:    name,
+		started: make(map[*runnableNode]struct{}),
+	}, nil
+}
+
+func (p *runnablePool) start(ctx context.Context) error {
+	p.logger.Info(fmt.Sprintf("Graceful: Run %s...", p.name))
+
+	for _, level := range p.levels {
+		var (
+			wg   sync.WaitGroup
+			mu   sync.Mutex
+			errs []error
+		)
+
+		wg.Add(len(level))
+
+		for _, n := range level {
+			go func(n *runnableNode) {
+				defer wg.Done()
+
+				if err := n.r.Start(ctx); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("run %s `%s`... err: %w", p.name, n.name, err))
+					mu.Unlock()
+
+					return
+				}
+
+				p.setStarted(n, true)
 
-	for _, s := range pool {
-		if err := s.Start(ctx); err != nil {
-			return fmt.Errorf("run %s... err: %w", name, err)
+				p.logger.Info(fmt.Sprintf("Graceful: %s `%s` started", p.name, n.name))
+			}(n)
 		}
 
-		logger.Info(fmt.Sprintf("Graceful: %s `%T` started", name, s))
+		wg.Wait()
+
+		if len(errs) != 0 {
+			return errors.Join(errs...)
+		}
 	}
 
-	logger.Info(fmt.Sprintf("Graceful: All %s run", name))
+	p.logger.Info(fmt.Sprintf("Graceful: All %s run", p.name))
 
 	return nil
 }
 
-func stop(ctx context.Context, pool []Runnable, logger *zap.Logger, name runnableType) {
-	logger.Info(fmt.Sprintf("Graceful: Stopping %s...", name))
+// stop останавливает только те Runnable, которые успели запуститься, поэтому годится и для
+// штатной остановки, и для отката после неудачного старта.
+func (p *runnablePool) stop(ctx context.Context) error {
+	p.logger.Info(fmt.Sprintf("Graceful: Stopping %s...", p.name))
+
+	var errs []error
+
+	for i := len(p.levels) - 1; i >= 0; i-- {
+		if err := p.stopLevel(ctx, p.levels[i]); err != nil {
+			errs = append(errs, err)
+		}
+
+		if ctx.Err() != nil {
+			p.logger.Error("Graceful: Stop aborted by context done")
+
+			return errors.Join(append(errs, fmt.Errorf("stop %s... aborted: %w", p.name, ctx.Err()))...)
+		}
+	}
+
+	p.logger.Info(fmt.Sprintf("Graceful: All %s stopped", p.name))
+
+	return errors.Join(errs...)
+}
+
+func (p *runnablePool) stopLevel(ctx context.Context, level []*runnableNode) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
 
-	var wg sync.WaitGroup
+	for _, n := range level {
+		if !p.isStarted(n) {
+			continue
+		}
 
-	wg.Add(len(pool))
+		wg.Add(1)
 
-	for _, r := range pool {
-		go func(r Runnable) {
+		go func(n *runnableNode) {
 			defer wg.Done()
+			defer p.setStarted(n, false)
 
-			if err := r.Stop(ctx); err != nil {
-				logger.Error(fmt.Sprintf("stop %s...", name), zap.Error(err))
+			if err := n.r.Stop(ctx); err != nil {
+				p.logger.Error(fmt.Sprintf("stop %s `%s`...", p.name, n.name), zap.Error(err))
+
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("stop %s `%s`... err: %w", p.name, n.name, err))
+				mu.Unlock()
 
 				return
 			}
 
-			logger.Info(fmt.Sprintf("Graceful: %s `%T` stoped", name, r))
-		}(r)
+			p.logger.Info(fmt.Sprintf("Graceful: %s `%s` stoped", p.name, n.name))
+		}(n)
 	}
 
 	done := make(chan struct{})
@@ -65,10 +181,126 @@ func stop(ctx context.Context, pool []Runnable, logger *zap.Logger, name runnabl
 		close(done)
 	}()
 
+	// по ctx.Done не ждём зависшие Stop, но ошибки уже остановившихся не теряем
 	select {
 	case <-done:
-		logger.Info(fmt.Sprintf("Graceful: All %s stopped", name))
 	case <-ctx.Done():
-		logger.Error("Graceful: Stop aborted by context done")
 	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+func (p *runnablePool) setStarted(n *runnableNode, started bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if started {
+		p.started[n] = struct{}{}
+		return
+	}
+
+	delete(p.started, n)
+}
+
+func (p *runnablePool) isStarted(n *runnableNode) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.started[n]
+	return ok
+}
+
+// sortRunnables раскладывает Runnable по уровням (алгоритм Кана). Внутри уровня сохраняется
+// порядок, в котором Runnable пришли из dig группы.
+func sortRunnables(pool []Runnable) ([][]*runnableNode, error) {
+	nodes := make([]*runnableNode, 0, len(pool))
+	byName := make(map[string]*runnableNode, len(pool))
+	typeCount := make(map[string]int, len(pool))
+
+	for i, r := range pool {
+		n := &runnableNode{r: r, idx: i}
+
+		if named, ok := r.(NamedRunnable); ok {
+			n.name = named.Name()
+		} else {
+			n.name = fmt.Sprintf("%T", r)
+			if typeCount[n.name]++; typeCount[n.name] > 1 {
+				n.name = fmt.Sprintf("%s#%d", n.name, typeCount[n.name])
+			}
+		}
+
+		if _, ok := byName[n.name]; ok {
+			return nil, fmt.Errorf("%w: `%s`", ErrRunnableDuplicateName, n.name)
+		}
+
+		if dependent, ok := r.(DependentRunnable); ok {
+			n.deps = dependent.DependsOn()
+		}
+
+		byName[n.name] = n
+		nodes = append(nodes, n)
+	}
+
+	inDegree := make(map[*runnableNode]int, len(nodes))
+	dependents := make(map[*runnableNode][]*runnableNode, len(nodes))
+
+	for _, n := range nodes {
+		for _, dep := range n.deps {
+			d, ok := byName[dep]
+			if !ok {
+				return nil, fmt.Errorf("%w: `%s` depends on `%s`", ErrRunnableUnknownDep, n.name, dep)
+			}
+
+			inDegree[n]++
+			dependents[d] = append(dependents[d], n)
+		}
+	}
+
+	var (
+		levels  [][]*runnableNode
+		current []*runnableNode
+		sorted  int
+	)
+
+	for _, n := range nodes {
+		if inDegree[n] == 0 {
+			current = append(current, n)
+		}
+	}
+
+	for len(current) != 0 {
+		levels = append(levels, current)
+		sorted += len(current)
+
+		var next []*runnableNode
+		for _, n := range current {
+			for _, d := range dependents[n] {
+				if inDegree[d]--; inDegree[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+
+		sort.Slice(next, func(i, j int) bool {
+			return next[i].idx < next[j].idx
+		})
+
+		current = next
+	}
+
+	if sorted != len(nodes) {
+		cycled := make([]string, 0, len(nodes)-sorted)
+		for _, n := range nodes {
+			if inDegree[n] > 0 {
+				cycled = append(cycled, n.name)
+			}
+		}
+
+		return nil, fmt.Errorf("%w: %s", ErrRunnableCycle, strings.Join(cycled, ", "))
+	}
+
+	return levels, nil
 }
@@ -0,0 +1,213 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type (
+	testRunnable struct {
+		name     string
+		deps     []string
+		log      *testLog
+		startErr error
+		stopErr  error
+		// stopHang Stop не возвращается, пока не отменён контекст
+		stopHang bool
+	}
+
+	testLog struct {
+		mu     sync.Mutex
+		events []string
+	}
+)
+
+func (r *testRunnable) Name() string {
+	return r.name
+}
+
+func (r *testRunnable) DependsOn() []string {
+	return r.deps
+}
+
+func (r *testRunnable) Start(context.Context) error {
+	if r.startErr != nil {
+		return r.startErr
+	}
+
+	r.log.add("start " + r.name)
+	return nil
+}
+
+func (r *testRunnable) Stop(ctx context.Context) error {
+	r.log.add("stop " + r.name)
+
+	if r.stopHang {
+		<-ctx.Done()
+	}
+
+	return r.stopErr
+}
+
+func (l *testLog) add(e string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, e)
+}
+
+func (l *testLog) index(e string) int {
+	for i := range l.events {
+		if l.events[i] == e {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func TestSortRunnables(t *testing.T) {
+	for name, tt := range map[string]struct {
+		pool   []Runnable
+		levels [][]string
+		err    error
+	}{
+		"without deps": {
+			pool: []Runnable{
+				&testRunnable{name: "a"},
+				&testRunnable{name: "b"},
+			},
+			levels: [][]string{{"a", "b"}},
+		},
+		"chain": {
+			pool: []Runnable{
+				&testRunnable{name: "consumer", deps: []string{"leader"}},
+				&testRunnable{name: "leader", deps: []string{"cache"}},
+				&testRunnable{name: "cache"},
+			},
+			levels: [][]string{{"cache"}, {"leader"}, {"consumer"}},
+		},
+		"diamond": {
+			pool: []Runnable{
+				&testRunnable{name: "d", deps: []string{"b", "c"}},
+				&testRunnable{name: "c", deps: []string{"a"}},
+				&testRunnable{name: "b", deps: []string{"a"}},
+				&testRunnable{name: "a"},
+			},
+			levels: [][]string{{"a"}, {"c", "b"}, {"d"}},
+		},
+		"unknown dependency": {
+			pool: []Runnable{
+				&testRunnable{name: "a", deps: []string{"b"}},
+			},
+			err: ErrRunnableUnknownDep,
+		},
+		"cycle": {
+			pool: []Runnable{
+				&testRunnable{name: "a", deps: []string{"b"}},
+				&testRunnable{name: "b", deps: []string{"a"}},
+				&testRunnable{name: "c"},
+			},
+			err: ErrRunnableCycle,
+		},
+		"duplicate name": {
+			pool: []Runnable{
+				&testRunnable{name: "a"},
+				&testRunnable{name: "a"},
+			},
+			err: ErrRunnableDuplicateName,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			levels, err := sortRunnables(tt.pool)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			names := make([][]string, len(levels))
+			for i := range levels {
+				for _, n := range levels[i] {
+					names[i] = append(names[i], n.name)
+				}
+			}
+
+			require.Equal(t, tt.levels, names)
+		})
+	}
+}
+
+func TestRunnablePoolOrder(t *testing.T) {
+	l := &testLog{}
+
+	p, err := newRunnablePool([]Runnable{
+		&testRunnable{name: "consumer", deps: []string{"leader"}, log: l},
+		&testRunnable{name: "leader", log: l},
+	}, zap.NewNop(), runnableService)
+	require.NoError(t, err)
+
+	require.NoError(t, p.start(context.Background()))
+	require.NoError(t, p.stop(context.Background()))
+
+	require.Less(t, l.index("start leader"), l.index("start consumer"))
+	require.Less(t, l.index("stop consumer"), l.index("stop leader"))
+}
+
+func TestRunnablePoolRollback(t *testing.T) {
+	var (
+		l        = &testLog{}
+		errStart = errors.New("start failed")
+		errStop  = errors.New("stop failed")
+	)
+
+	p, err := newRunnablePool([]Runnable{
+		&testRunnable{name: "cache", log: l},
+		&testRunnable{name: "leader", log: l, stopErr: errStop},
+		&testRunnable{name: "consumer", deps: []string{"leader"}, log: l, startErr: errStart},
+		&testRunnable{name: "producer", deps: []string{"consumer"}, log: l},
+	}, zap.NewNop(), runnableService)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, p.start(context.Background()), errStart)
+
+	err = p.stop(context.Background())
+	require.ErrorIs(t, err, errStop)
+
+	require.Equal(t, -1, l.index("stop consumer"))
+	require.Equal(t, -1, l.index("stop producer"))
+	require.NotEqual(t, -1, l.index("stop cache"))
+	require.NotEqual(t, -1, l.index("stop leader"))
+
+	require.NoError(t, p.stop(context.Background()))
+}
+
+func TestRunnablePoolStopTimeout(t *testing.T) {
+	var (
+		l       = &testLog{}
+		errStop = errors.New("stop failed")
+	)
+
+	p, err := newRunnablePool([]Runnable{
+		&testRunnable{name: "leader", log: l, stopErr: errStop},
+		&testRunnable{name: "consumer", log: l, stopHang: true},
+	}, zap.NewNop(), runnableService)
+	require.NoError(t, err)
+
+	require.NoError(t, p.start(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// ошибки остановившихся до отмены контекста не теряются
+	err = p.stop(ctx)
+	require.ErrorIs(t, err, errStop)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -16,24 +16,27 @@ type (
 	ServerList []Runnable
 
 	ServerPool struct {
-		p      []Runnable
-		logger *zap.Logger
+		p *runnablePool
 	}
 )
 
-func NewServerPool(sl ServerList, logger *zap.Logger) *ServerPool {
-	return &ServerPool{
-		p:      sl,
-		logger: logger,
+func NewServerPool(sl ServerList, logger *zap.Logger) (*ServerPool, error) {
+	p, err := newRunnablePool(sl, logger, runnableServer)
+	if err != nil {
+		return nil, err
 	}
+
+	return &ServerPool{
+		p: p,
+	}, nil
 }
 
 func (p *ServerPool) Start(ctx context.Context) error {
-	return start(ctx, p.p, p.logger, runnableServer)
+	return p.p.start(ctx)
 }
 
-func (p *ServerPool) Stop(ctx context.Context) {
-	stop(ctx, p.p, p.logger, runnableServer)
+func (p *ServerPool) Stop(ctx context.Context) error {
+	return p.p.stop(ctx)
 }
 
 func StartServerWithWaiting(
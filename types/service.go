@@ -10,22 +10,25 @@ type (
 	ServiceList []Runnable
 
 	ServicePool struct {
-		p      []Runnable
-		logger *zap.Logger
+		p *runnablePool
 	}
 )
 
-func NewServicePool(sl ServiceList, logger *zap.Logger) *ServicePool {
-	return &ServicePool{
-		p:      sl,
-		logger: logger,
+func NewServicePool(sl ServiceList, logger *zap.Logger) (*ServicePool, error) {
+	p, err := newRunnablePool(sl, logger, runnableService)
+	if err != nil {
+		return nil, err
 	}
+
+	return &ServicePool{
+		p: p,
+	}, nil
 }
 
 func (p *ServicePool) Start(ctx context.Context) error {
-	return start(ctx, p.p, p.logger, runnableService)
+	return p.p.start(ctx)
 }
 
-func (p *ServicePool) Stop(ctx context.Context) {
-	stop(ctx, p.p, p.logger, runnableService)
+func (p *ServicePool) Stop(ctx context.Context) error {
+	return p.p.stop(ctx)
 }